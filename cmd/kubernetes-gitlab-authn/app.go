@@ -7,39 +7,108 @@ import (
 
 	slogadapter "github.com/UiP9AV6Y/go-slog-adapter"
 
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/cache"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/config"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/handler"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
 )
 
-func newAppRouter(reg *metrics.Metrics, users *cache.UserInfoCache, logger *slogadapter.SlogAdapter, cfg *config.Config) (http.Handler, error) {
-	router := http.NewServeMux()
-	baseURL, err := cfg.Gitlab.URL()
+func newGitlabClient(httpClient *http.Client, logger *slogadapter.SlogAdapter, cfg *config.Gitlab) (*gitlab.Client, error) {
+	baseURL, err := cfg.URL()
 	if err != nil {
 		return nil, err
 	}
 
-	httpClient, err := cfg.Gitlab.HTTPClient()
+	return gitlab.NewClient("",
+		gitlab.WithBaseURL(baseURL.String()),
+		gitlab.WithHTTPClient(httpClient),
+		gitlab.WithoutRetries(), // handled by the HTTP client middlewares
+		gitlab.WithCustomLeveledLogger(logger.Logger()),
+	)
+}
+
+func newIdentitySource(apiClient *gitlab.Client, httpClient *http.Client, reg *metrics.Metrics, logger *slogadapter.SlogAdapter, cfg *config.Gitlab) (identity.Source, error) {
+	serviceToken, err := cfg.ServiceAccountToken()
 	if err != nil {
 		return nil, err
 	}
 
-	apiClient, err := gitlab.NewClient("",
-		gitlab.WithBaseURL(baseURL.String()),
-		gitlab.WithHTTPClient(httpClient),
-		gitlab.WithCustomLeveledLogger(logger.Logger()),
+	graphQL, err := cfg.GraphQL()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenTypes, err := cfg.TokenClassifier()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Gitea() {
+		baseURL, err := cfg.URL()
+		if err != nil {
+			return nil, err
+		}
+
+		return identity.NewGiteaSource(httpClient, baseURL, logger.Logger(),
+			identity.WithGiteaPageLimit(cfg.GroupFilter.PageLimit()),
+			identity.WithGiteaMetrics(reg),
+		)
+	}
+
+	return identity.NewGitlabSource(apiClient, logger.Logger(),
+		identity.WithGitlabGroupFilter(cfg.GroupFilter.ListOptions()),
+		identity.WithGitlabGroupPageLimit(cfg.GroupFilter.PageLimit()),
+		identity.WithGitlabServiceToken(serviceToken),
+		identity.WithGitlabGraphQL(graphQL),
+		identity.WithGitlabTokenIntrospection(cfg.TokenIntrospection),
+		identity.WithGitlabTokenTypes(tokenTypes),
+		identity.WithGitlabMetrics(reg),
 	)
+}
+
+func newAppRouter(source identity.Source, reg *metrics.Metrics, users *cache.UserInfoCache, logger *slogadapter.SlogAdapter, cfg *config.Config) (http.Handler, error) {
+	router := http.NewServeMux()
+	instances, err := cfg.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL, err := instances[0].URL()
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := cfg.TokenValidator()
 	if err != nil {
 		return nil, err
 	}
 
-	authHandler, err := handler.NewAuthHandler(apiClient, logger.Logger(),
-		handler.WithAuthGroupFilter(cfg.Gitlab.GroupFilter.ListOptions()),
-		handler.WithAuthTokenValidator(cfg.Gitlab.TokenValidator()),
-		handler.WithAuthUserTransform(cfg.Gitlab.UserInfoOptions()),
-		handler.WithAuthUserACLs(cfg.Realms.UserAccessControlList()),
+	classifier, err := cfg.TokenClassifier()
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := cfg.UserAccessControlList()
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := make(map[string]*access.UserInfoOptions, len(instances))
+	for _, inst := range instances {
+		userInfo[inst.Name] = inst.UserInfoOptions()
+	}
+
+	authHandler, err := handler.NewAuthHandler(source, logger.Logger(),
+		handler.WithAuthTokenValidator(validator),
+		handler.WithAuthTokenTypes(classifier),
+		handler.WithAuthUserTransform(instances[0].UserInfoOptions()),
+		handler.WithAuthBackendUserTransform(userInfo),
+		handler.WithAuthUserACLs(acls),
 		handler.WithAuthUserCache(users),
+		handler.WithAuthNegativeCacheTTL(cfg.Cache.NegativeExpirationTime()),
+		handler.WithAuthLookupTimeout(cfg.Server.LookupTimeout.Duration),
 		handler.WithAuthMetrics(reg),
 	)
 	if err != nil {
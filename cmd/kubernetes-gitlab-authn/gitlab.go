@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	slogadapter "github.com/UiP9AV6Y/go-slog-adapter"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/compat"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/config"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/health"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+// upstreamHealth degrades the given status while the
+// circuit breaker of any Gitlab instance is open.
+type upstreamHealth struct {
+	mu     sync.Mutex
+	open   map[string]bool
+	status *health.Health
+}
+
+func newUpstreamHealth(status *health.Health) *upstreamHealth {
+	status.Restore()
+
+	return &upstreamHealth{
+		open:   map[string]bool{},
+		status: status,
+	}
+}
+
+func (u *upstreamHealth) Update(name string, state transport.CircuitState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if state == transport.CircuitOpen {
+		u.open[name] = true
+	} else {
+		delete(u.open, name)
+	}
+
+	if len(u.open) > 0 {
+		u.status.Degrade()
+	} else {
+		u.status.Restore()
+	}
+}
+
+// gitlabBackend bundles the clients of a Gitlab instance
+// along with the collectors of its transport middlewares.
+type gitlabBackend struct {
+	httpClient *http.Client
+	apiClient  *gitlab.Client
+	collectors []prometheus.Collector
+}
+
+func newGitlabBackend(name string, cfg *config.Gitlab, stats *metrics.Metrics, upstream *upstreamHealth, logger *slogadapter.SlogAdapter) (*gitlabBackend, error) {
+	breakerOpts := cfg.CircuitBreaker.CircuitBreakerOpts()
+	breakerOpts.OnStateChange = func(state transport.CircuitState) {
+		logger.Logger().Warn("Gitlab circuit breaker changed state", "backend", name, "state", state.String())
+		upstream.Update(name, state)
+	}
+	breaker := transport.NewCircuitBreaker(breakerOpts)
+	limiter := transport.NewRateLimiter(cfg.RateLimit.RateLimiterOpts())
+	retryOpts := cfg.Retry.RetryOpts()
+	retryOpts.OnRetry = func(r *http.Request, _ int) {
+		metrics.RetryInContext(r.Context())
+	}
+	retrier := transport.NewRetrier(retryOpts)
+	concurrencyOpts := cfg.Concurrency.ConcurrencyLimiterOpts()
+	concurrencyOpts.OnWait = stats.GitlabQueueWait
+	concurrency := transport.NewConcurrencyLimiter(concurrencyOpts)
+
+	httpClient, err := cfg.HTTPClient(
+		retrier.Middleware(),
+		concurrency.Middleware(),
+		limiter.Middleware(),
+		breaker.Middleware(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	apiClient, err := newGitlabClient(httpClient, logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &gitlabBackend{
+		httpClient: httpClient,
+		apiClient:  apiClient,
+		collectors: []prometheus.Collector{
+			transport.NewCircuitBreakerCollector(breaker.State, metrics.Namespace),
+			transport.NewRateLimitCollector(limiter.Status, metrics.Namespace),
+			transport.NewConcurrencyCollector(concurrency.Status, metrics.Namespace),
+		},
+	}
+
+	return result, nil
+}
+
+// checkGitlab queries the version of the Gitlab instance and
+// reports features which are not supported by it. An error is
+// only returned if Gitlab is unreachable and fail-fast is enabled.
+func checkGitlab(ctx context.Context, name string, client *gitlab.Client, stats *metrics.Metrics, logger *slogadapter.SlogAdapter, cfg *config.Gitlab) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.CompatibilityCheck.Timeout.Duration)
+	defer cancel()
+
+	instance, err := compat.Probe(ctx, client, cfg.ServiceToken)
+	if err != nil {
+		if compat.IsUnreachable(err) {
+			if cfg.CompatibilityCheck.FailFast && name != "" {
+				return fmt.Errorf("gitlab %q is unreachable: %w", name, err)
+			} else if cfg.CompatibilityCheck.FailFast {
+				return fmt.Errorf("gitlab is unreachable: %w", err)
+			}
+
+			logger.Logger().Warn("Gitlab is unreachable, unable to determine its version", "backend", name, "err", err)
+			return nil
+		}
+
+		if cfg.ServiceToken == "" {
+			logger.Info("Gitlab version is unknown, no service token configured", "backend", name)
+		} else {
+			logger.Logger().Warn("Gitlab rejected the version query", "backend", name, "err", err)
+		}
+		return nil
+	}
+
+	stats.GitlabInfo(name, instance.Version, instance.Revision, instance.Edition)
+	logger.Info("Connected to Gitlab", "backend", name, "version", instance.Version, "revision", instance.Revision, "edition", instance.Edition)
+
+	for _, req := range instance.Unsupported(cfg.Requirements()...) {
+		logger.Logger().Warn("Configured feature is not supported by Gitlab", "backend", name,
+			"feature", req.Feature, "required_version", req.Version, "version", instance.Version)
+	}
+
+	return nil
+}
@@ -9,16 +9,22 @@ import (
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/health"
 )
 
-func newHealthRouter(status *health.Health, _ *slogadapter.SlogAdapter, cfg *config.Health) (http.Handler, error) {
+func newHealthRouter(status, upstream *health.Health, _ *slogadapter.SlogAdapter, cfg *config.Health) (http.Handler, error) {
 	router := http.NewServeMux()
 	opts := handler.HealthHandlerOpts{}
-	handler, err := handler.HealthHandlerFor(status, opts)
+	statusHandler, err := handler.HealthHandlerFor(status, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	router.Handle(http.MethodGet+" "+cfg.Server.HandlerPath("health"), handler)
-	router.Handle(http.MethodGet+" "+cfg.Server.HandlerPath("ready"), handler)
+	upstreamHandler, err := handler.HealthHandlerFor(upstream, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	router.Handle(http.MethodGet+" "+cfg.Server.HandlerPath("health"), statusHandler)
+	router.Handle(http.MethodGet+" "+cfg.Server.HandlerPath("ready"), statusHandler)
+	router.Handle(http.MethodGet+" "+cfg.Server.HandlerPath("gitlab"), upstreamHandler)
 
 	return router, nil
 }
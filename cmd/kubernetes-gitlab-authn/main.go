@@ -27,6 +27,7 @@ import (
 	cfgflags "github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/config/stdflags"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/handler"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/health"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/version"
 )
@@ -44,6 +45,62 @@ func newHTTPServer(h http.Handler, ctx context.Context) *http.Server {
 	return result
 }
 
+// newBackends sets up the configured Gitlab instances. A router
+// dispatching lookups is only returned if the instances are named.
+func newBackends(ctx context.Context, registry *prometheus.Registry, stats *metrics.Metrics, upstream *upstreamHealth, logger *slogadapter.SlogAdapter, config *config.Config) (identity.Source, error) {
+	instances, err := config.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	backends := make([]*identity.Backend, len(instances))
+	for i, inst := range instances {
+		reg := prometheus.Registerer(registry)
+		if inst.Name != "" {
+			reg = prometheus.WrapRegistererWith(prometheus.Labels{"backend": inst.Name}, registry)
+		}
+
+		backendStats := stats.Backend(inst.Name)
+		backend, err := newGitlabBackend(inst.Name, &inst.Gitlab, backendStats, upstream, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range backend.collectors {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+
+		if inst.CompatibilityCheck.Enabled && !inst.Gitea() {
+			err = checkGitlab(ctx, inst.Name, backend.apiClient, stats, logger, &inst.Gitlab)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		source, err := newIdentitySource(backend.apiClient, backend.httpClient, backendStats, logger, &inst.Gitlab)
+		if err != nil {
+			return nil, err
+		}
+
+		backends[i] = &identity.Backend{
+			Name:      inst.Name,
+			Namespace: inst.NamespacePrefix(),
+			Source:    source,
+			Prefixes:  inst.TokenPrefixes,
+			Realms:    inst.Realms,
+			Fallback:  inst.Fallback,
+		}
+	}
+
+	if len(backends) == 1 && backends[0].Name == "" {
+		return backends[0].Source, nil
+	}
+
+	return identity.NewRouter(backends...), nil
+}
+
 func runServers(name string, config *config.Config, logger *slogadapter.SlogAdapter) (err error) {
 	var router http.Handler
 	var server *http.Server
@@ -74,8 +131,14 @@ func runServers(name string, config *config.Config, logger *slogadapter.SlogAdap
 		return
 	}
 
-	users := cache.NewUserInfoCache(config.Cache.ExpirationTime())
-	router, err = newAppRouter(stats, users, logger, config)
+	upstream := health.New()
+	source, err := newBackends(mainCtx, registry, stats, newUpstreamHealth(upstream), logger, config)
+	if err != nil {
+		return err
+	}
+
+	users := cache.NewUserInfoCache(config.Cache.UserInfoCacheOpts())
+	router, err = newAppRouter(source, stats, users, logger, config)
 	if err != nil {
 		return err
 	}
@@ -103,7 +166,7 @@ func runServers(name string, config *config.Config, logger *slogadapter.SlogAdap
 	}
 
 	if config.Health.Port > 0 {
-		router, err = newHealthRouter(status, logger, config.Health)
+		router, err = newHealthRouter(status, upstream, logger, config.Health)
 		if err != nil {
 			return err
 		}
@@ -8,12 +8,15 @@ require (
 	github.com/UiP9AV6Y/go-k8s-user-authz v0.3.0
 	github.com/UiP9AV6Y/go-slog-adapter v0.2.0
 	github.com/grafana/pyroscope-go/godeltaprof v0.1.8
+	github.com/hashicorp/go-retryablehttp v0.7.7
 	github.com/jellydator/ttlcache/v3 v3.3.0
 	github.com/prometheus/client_golang v1.20.5
 	gitlab.com/gitlab-org/api/client-go v0.118.0
+	golang.org/x/net v0.30.0
 	golang.org/x/sync v0.10.0
 	k8s.io/api v0.32.0
 	k8s.io/apimachinery v0.32.0
+	k8s.io/apiserver v0.32.0
 	sigs.k8s.io/yaml v1.4.0
 )
 
@@ -26,7 +29,6 @@ require (
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/google/gofuzz v1.2.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
-	github.com/hashicorp/go-retryablehttp v0.7.7 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -36,14 +38,12 @@ require (
 	github.com/prometheus/common v0.55.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
-	golang.org/x/net v0.30.0 // indirect
 	golang.org/x/oauth2 v0.23.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
 	golang.org/x/time v0.7.0 // indirect
 	google.golang.org/protobuf v1.36.0 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
-	k8s.io/apiserver v0.32.0 // indirect
 	k8s.io/klog/v2 v2.130.1 // indirect
 	k8s.io/utils v0.0.0-20241104100929-3ea5e8cea738 // indirect
 	sigs.k8s.io/json v0.0.0-20241010143419-9aa6b5e7a4b3 // indirect
@@ -15,6 +15,7 @@ type DataAccess struct {
 	Tokens TokensAccess
 	Groups GroupsAccess
 	Users  UsersAccess
+	Jobs   JobsAccess
 }
 
 type TokensAccess interface {
@@ -33,3 +34,8 @@ type GroupsAccess interface {
 	FindByUserIdentifier(uid, offset, size int) ([]*gitlab.Group, error)
 	CountByUserIdentifier(uid int) (int, error)
 }
+
+type JobsAccess interface {
+	Create(token string, job *Job) error
+	FindByToken(token string) (*Job, error)
+}
@@ -22,11 +22,26 @@ var (
 	confirmed = time.Date(2019, time.January, 23, 10, 0, 0, 0, time.UTC)
 	// simulated time of creation
 	created = time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// simulated token expiration; Gitlab limits the lifetime to one year
+	tokenExpiry = gitlab.ISOTime(signIn.AddDate(1, 0, 0).Truncate(time.Hour * 24))
 )
 
+// scopes of all mock tokens
+var tokenScopes = []string{"read_user", "read_api"}
+
 // User provider property
 var userProvider = "gitlab-mock"
 
+// user account states
+var (
+	userStateActive      = "active"
+	userStateBlocked     = "blocked"
+	userStateDeactivated = "deactivated"
+	userStateBanned      = "banned"
+	userStateLDAPBlocked = "ldap_blocked"
+	userStatePending     = "blocked_pending_approval"
+)
+
 // shared user attributes
 var customUserAttributes = []*gitlab.CustomAttribute{
 	&gitlab.CustomAttribute{
@@ -43,6 +58,7 @@ var (
 		Email:            "root@localhost.localdomain",
 		Name:             "Admin User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -59,6 +75,7 @@ var (
 		Email:            "mock@localhost.localdomain",
 		Name:             "Mock User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -74,6 +91,7 @@ var (
 		Email:            "secure@localhost.localdomain",
 		Name:             "Secure User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -90,6 +108,7 @@ var (
 		Email:            "private@localhost.localdomain",
 		Name:             "Private User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -106,6 +125,7 @@ var (
 		Email:            "external@localhost.localdomain",
 		Name:             "External User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -122,6 +142,7 @@ var (
 		Email:            "bot@localhost.localdomain",
 		Name:             "Bot User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -138,6 +159,7 @@ var (
 		Email:            "locked@localhost.localdomain",
 		Name:             "Locked User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -154,6 +176,7 @@ var (
 		Email:            "pristine@localhost.localdomain",
 		Name:             "Pristine User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CreatedAt:        &created,
 	}
@@ -163,6 +186,7 @@ var (
 		Email:            "dormant@localhost.localdomain",
 		Name:             "Dormant User",
 		Provider:         userProvider,
+		State:            userStateActive,
 		CustomAttributes: customUserAttributes,
 		CurrentSignInIP:  &netAddress,
 		LastSignInIP:     &netAddress,
@@ -172,6 +196,66 @@ var (
 		LastActivityOn:   &inactivity,
 		CreatedAt:        &created,
 	}
+	blockedUser = gitlab.User{
+		ID:               1100,
+		Username:         "blocked",
+		Email:            "blocked@localhost.localdomain",
+		Name:             "Blocked User",
+		Provider:         userProvider,
+		State:            userStateBlocked,
+		CustomAttributes: customUserAttributes,
+		ConfirmedAt:      &confirmed,
+		LastActivityOn:   &activity,
+		CreatedAt:        &created,
+	}
+	deactivatedUser = gitlab.User{
+		ID:               1200,
+		Username:         "deactivated",
+		Email:            "deactivated@localhost.localdomain",
+		Name:             "Deactivated User",
+		Provider:         userProvider,
+		State:            userStateDeactivated,
+		CustomAttributes: customUserAttributes,
+		ConfirmedAt:      &confirmed,
+		LastActivityOn:   &activity,
+		CreatedAt:        &created,
+	}
+	bannedUser = gitlab.User{
+		ID:               1300,
+		Username:         "banned",
+		Email:            "banned@localhost.localdomain",
+		Name:             "Banned User",
+		Provider:         userProvider,
+		State:            userStateBanned,
+		CustomAttributes: customUserAttributes,
+		ConfirmedAt:      &confirmed,
+		LastActivityOn:   &activity,
+		CreatedAt:        &created,
+	}
+	ldapBlockedUser = gitlab.User{
+		ID:               1400,
+		Username:         "ldap-blocked",
+		Email:            "ldap-blocked@localhost.localdomain",
+		Name:             "LDAP Blocked User",
+		Provider:         userProvider,
+		State:            userStateLDAPBlocked,
+		CustomAttributes: customUserAttributes,
+		ConfirmedAt:      &confirmed,
+		LastActivityOn:   &activity,
+		CreatedAt:        &created,
+	}
+	pendingUser = gitlab.User{
+		ID:               1500,
+		Username:         "pending",
+		Email:            "pending@localhost.localdomain",
+		Name:             "Pending User",
+		Provider:         userProvider,
+		State:            userStatePending,
+		CustomAttributes: customUserAttributes,
+		ConfirmedAt:      &confirmed,
+		LastActivityOn:   &activity,
+		CreatedAt:        &created,
+	}
 )
 
 // group models
@@ -230,3 +314,111 @@ var (
 		Visibility:  gitlab.InternalVisibility,
 	}
 )
+
+// project models
+var (
+	deployProject = gitlab.Project{
+		ID:                100,
+		Description:       "Deployment pipelines",
+		Name:              "Deploy",
+		Path:              "deploy",
+		PathWithNamespace: "core/deploy",
+		DefaultBranch:     protectedBranch,
+		CreatedAt:         &created,
+		Visibility:        gitlab.InternalVisibility,
+		Namespace: &gitlab.ProjectNamespace{
+			ID:       coreGroup.ID,
+			Name:     coreGroup.Name,
+			Path:     coreGroup.Path,
+			Kind:     "group",
+			FullPath: coreGroup.FullPath,
+		},
+	}
+)
+
+// name of the only protected branch
+var protectedBranch = "main"
+
+// Job is the response of the job token API,
+// which includes the environment of deployment jobs.
+type Job struct {
+	gitlab.Job
+
+	Environment *JobEnvironment `json:"environment,omitempty"`
+}
+
+// JobEnvironment is the deployment target of a job
+type JobEnvironment struct {
+	Name string `json:"name"`
+}
+
+// job models
+var (
+	deployJob = Job{
+		Job: gitlab.Job{
+			ID:        1001,
+			Name:      "deploy",
+			Stage:     "deploy",
+			Status:    "running",
+			Ref:       protectedBranch,
+			CreatedAt: &created,
+			Project:   &deployProject,
+			User:      &mockUser,
+		},
+		Environment: &JobEnvironment{
+			Name: "production",
+		},
+	}
+	featureJob = Job{
+		Job: gitlab.Job{
+			ID:        1002,
+			Name:      "test",
+			Stage:     "test",
+			Status:    "running",
+			Ref:       "feature",
+			CreatedAt: &created,
+			Project:   &deployProject,
+			User:      &mockUser,
+		},
+	}
+)
+
+func init() {
+	deployJob.Pipeline.ID = 101
+	deployJob.Pipeline.ProjectID = deployProject.ID
+	deployJob.Pipeline.Ref = deployJob.Ref
+	featureJob.Pipeline.ID = 102
+	featureJob.Pipeline.ProjectID = deployProject.ID
+	featureJob.Pipeline.Ref = featureJob.Ref
+}
+
+// ProtectedBranch returns the protection settings of the branch
+// with the given name or nil if the branch is not protected.
+func ProtectedBranch(pid int, name string) *gitlab.ProtectedBranch {
+	if pid != deployProject.ID || name != protectedBranch {
+		return nil
+	}
+
+	result := &gitlab.ProtectedBranch{
+		ID:   pid,
+		Name: name,
+	}
+
+	return result
+}
+
+// PersonalAccessToken returns the details of the token
+// used to authenticate as the user with the given ID.
+func PersonalAccessToken(uid int) *gitlab.PersonalAccessToken {
+	result := &gitlab.PersonalAccessToken{
+		ID:        uid * 10,
+		Name:      "gitlab-authn",
+		Scopes:    tokenScopes,
+		UserID:    uid,
+		Active:    true,
+		CreatedAt: &created,
+		ExpiresAt: &tokenExpiry,
+	}
+
+	return result
+}
@@ -11,6 +11,7 @@ type storage struct {
 	groups     map[int]*gitlab.Group
 	userGroups map[int][]int
 	tokenUsers map[string]int
+	tokenJobs  map[string]*model.Job
 }
 
 // NewDataAccess returns a cheap [model.DataAccess] implementation
@@ -22,6 +23,7 @@ func NewDataAccess() (*model.DataAccess, error) {
 		groups:     map[int]*gitlab.Group{},
 		userGroups: map[int][]int{},
 		tokenUsers: map[string]int{},
+		tokenJobs:  map[string]*model.Job{},
 	}
 	tokens := &tokensAccess{
 		s: s,
@@ -32,10 +34,14 @@ func NewDataAccess() (*model.DataAccess, error) {
 	users := &usersAccess{
 		s: s,
 	}
+	jobs := &jobsAccess{
+		s: s,
+	}
 	result := &model.DataAccess{
 		Tokens: tokens,
 		Groups: groups,
 		Users:  users,
+		Jobs:   jobs,
 	}
 
 	return result, nil
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/gitlab-mock/internal/model"
+)
+
+type jobsAccess struct {
+	s *storage
+}
+
+func (j *jobsAccess) Create(token string, job *model.Job) error {
+	if _, ok := j.s.tokenJobs[token]; ok {
+		return model.ErrConflict
+	}
+
+	j.s.tokenJobs[token] = job
+
+	return nil
+}
+
+func (j *jobsAccess) FindByToken(token string) (*model.Job, error) {
+	job, ok := j.s.tokenJobs[token]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return job, nil
+}
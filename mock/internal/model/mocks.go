@@ -7,20 +7,28 @@ import (
 )
 
 var (
-	mockTokenAdmin    = "ADMIN-00000000000-TOKEN"
-	mockTokenMock     = "MOCK-000000000000-TOKEN"
-	mockTokenSecure   = "SECURE-0000000000-TOKEN"
-	mockTokenPrivate  = "PRIVATE-000000000-TOKEN"
-	mockTokenExternal = "EXTERNAL-00000000-TOKEN"
-	mockTokenBot      = "BOT-0000000000000-TOKEN"
-	mockTokenLocked   = "LOCKED-0000000000-TOKEN"
-	mockTokenPristine = "PRISTINE-00000000-TOKEN"
-	mockTokenDormant  = "DORMANT-000000000-TOKEN"
+	mockTokenAdmin       = "ADMIN-00000000000-TOKEN"
+	mockTokenMock        = "MOCK-000000000000-TOKEN"
+	mockTokenSecure      = "SECURE-0000000000-TOKEN"
+	mockTokenPrivate     = "PRIVATE-000000000-TOKEN"
+	mockTokenExternal    = "EXTERNAL-00000000-TOKEN"
+	mockTokenBot         = "BOT-0000000000000-TOKEN"
+	mockTokenLocked      = "LOCKED-0000000000-TOKEN"
+	mockTokenPristine    = "PRISTINE-00000000-TOKEN"
+	mockTokenDormant     = "DORMANT-000000000-TOKEN"
+	mockTokenBlocked     = "BLOCKED-000000000-TOKEN"
+	mockTokenDeactivated = "DEACTIVATED-00000-TOKEN"
+	mockTokenBanned      = "BANNED-0000000000-TOKEN"
+	mockTokenLDAPBlocked = "LDAPBLOCKED-00000-TOKEN"
+	mockTokenPending     = "PENDING-000000000-TOKEN"
+	mockTokenDeploy      = "DEPLOY-0000000000-TOKEN"
+	mockTokenFeature     = "FEATURE-000000000-TOKEN"
 )
 
 type Mocks struct {
-	TokenPrefix string
-	GroupCount  uint64
+	TokenPrefix    string
+	JobTokenPrefix string
+	GroupCount     uint64
 }
 
 func (m *Mocks) Create(dao *DataAccess) error {
@@ -28,6 +36,10 @@ func (m *Mocks) Create(dao *DataAccess) error {
 		return err
 	}
 
+	if err := m.createJobs(dao.Jobs); err != nil {
+		return err
+	}
+
 	if err := m.createUsers(dao.Users); err != nil {
 		return err
 	}
@@ -41,15 +53,20 @@ func (m *Mocks) Create(dao *DataAccess) error {
 
 func (m *Mocks) createTokens(dao TokensAccess) error {
 	models := map[string]int{
-		mockTokenAdmin:    adminUser.ID,
-		mockTokenMock:     mockUser.ID,
-		mockTokenSecure:   secureUser.ID,
-		mockTokenPrivate:  privateUser.ID,
-		mockTokenExternal: externalUser.ID,
-		mockTokenBot:      botUser.ID,
-		mockTokenLocked:   lockedUser.ID,
-		mockTokenPristine: pristineUser.ID,
-		mockTokenDormant:  dormantUser.ID,
+		mockTokenAdmin:       adminUser.ID,
+		mockTokenMock:        mockUser.ID,
+		mockTokenSecure:      secureUser.ID,
+		mockTokenPrivate:     privateUser.ID,
+		mockTokenExternal:    externalUser.ID,
+		mockTokenBot:         botUser.ID,
+		mockTokenLocked:      lockedUser.ID,
+		mockTokenPristine:    pristineUser.ID,
+		mockTokenDormant:     dormantUser.ID,
+		mockTokenBlocked:     blockedUser.ID,
+		mockTokenDeactivated: deactivatedUser.ID,
+		mockTokenBanned:      bannedUser.ID,
+		mockTokenLDAPBlocked: ldapBlockedUser.ID,
+		mockTokenPending:     pendingUser.ID,
 	}
 
 	for t, u := range models {
@@ -61,6 +78,21 @@ func (m *Mocks) createTokens(dao TokensAccess) error {
 	return nil
 }
 
+func (m *Mocks) createJobs(dao JobsAccess) error {
+	models := map[string]*Job{
+		mockTokenDeploy:  &deployJob,
+		mockTokenFeature: &featureJob,
+	}
+
+	for t, j := range models {
+		if err := dao.Create(m.JobTokenPrefix+t, j); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *Mocks) createUsers(dao UsersAccess) error {
 	models := []*gitlab.User{
 		&adminUser,
@@ -72,6 +104,11 @@ func (m *Mocks) createUsers(dao UsersAccess) error {
 		&lockedUser,
 		&pristineUser,
 		&dormantUser,
+		&blockedUser,
+		&deactivatedUser,
+		&bannedUser,
+		&ldapBlockedUser,
+		&pendingUser,
 	}
 
 	for _, u := range models {
@@ -146,6 +183,21 @@ func (m *Mocks) createGroupAccociations(dao GroupsAccess) error {
 		dormantUser.ID: []int{
 			coreGroup.ID, userGroup.ID, specialGroup.ID,
 		},
+		blockedUser.ID: []int{
+			coreGroup.ID, userGroup.ID, specialGroup.ID,
+		},
+		deactivatedUser.ID: []int{
+			coreGroup.ID, userGroup.ID, specialGroup.ID,
+		},
+		bannedUser.ID: []int{
+			coreGroup.ID, userGroup.ID, specialGroup.ID,
+		},
+		ldapBlockedUser.ID: []int{
+			coreGroup.ID, userGroup.ID, specialGroup.ID,
+		},
+		pendingUser.ID: []int{
+			coreGroup.ID, userGroup.ID, specialGroup.ID,
+		},
 	}
 
 	for u, gs := range assoc {
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/gitlab-mock/internal/model"
+)
+
+// mockAccessLevel is reported for all group memberships
+const mockAccessLevel = gitlab.DeveloperPermissions
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// parseGraphQLRequest supports queries sent as JSON body (POST)
+// and as query parameters (GET).
+func parseGraphQLRequest(req *http.Request) (*graphQLRequest, error) {
+	result := &graphQLRequest{}
+	if req.Method == http.MethodPost {
+		err := json.NewDecoder(req.Body).Decode(result)
+		return result, err
+	}
+
+	q := req.URL.Query()
+	result.Query = q.Get("query")
+	if v := q.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &result.Variables); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// GraphQLHandler answers the current user query issued by
+// kubernetes-gitlab-authn. The query itself is not interpreted;
+// the response always contains the user and one page of their
+// group memberships, as selected by the first/after variables.
+// Like Gitlab, invalid tokens result in an anonymous response.
+func GraphQLHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		query, err := parseGraphQLRequest(req)
+		if err != nil {
+			logger.Info("GraphQL request is malformed", "err", err)
+			respondError(w, http.StatusBadRequest, "invalid query")
+			return
+		}
+
+		data := map[string]interface{}{
+			"currentUser": nil,
+		}
+		result := map[string]interface{}{
+			"data": data,
+		}
+
+		auth := parseToken(req)
+		uid, err := dao.Tokens.FindUserIdentifier(auth)
+		if err != nil {
+			logger.Info("GraphQL request without valid authentication", "token", auth)
+			respondDTO(w, result)
+			return
+		}
+
+		user, err := dao.Users.FindByIdentifier(uid)
+		if err != nil {
+			logger.Info("GraphQL request yielded no user", "uid", uid, "err", err)
+			respondError(w, http.StatusInternalServerError, "user lookup failed")
+			return
+		}
+
+		size := DefaultBatchSize
+		if v, ok := query.Variables["first"].(float64); ok && v > 0 && v <= MaxBatchSize {
+			size = int(v)
+		}
+
+		offset := 0
+		if v, ok := query.Variables["after"].(string); ok {
+			offset, _ = strconv.Atoi(v)
+		}
+
+		groups, err := dao.Groups.FindByUserIdentifier(uid, offset, size)
+		if err != nil {
+			logger.Info("GraphQL request yielded no groups", "uid", uid, "err", err)
+			respondError(w, http.StatusInternalServerError, "groups lookup failed")
+			return
+		}
+
+		total, err := dao.Groups.CountByUserIdentifier(uid)
+		if err != nil {
+			logger.Info("Unable to count user groups", "uid", uid, "err", err)
+			respondError(w, http.StatusInternalServerError, "groups total failed")
+			return
+		}
+
+		nodes := make([]interface{}, len(groups))
+		for i, g := range groups {
+			nodes[i] = map[string]interface{}{
+				"accessLevel": map[string]interface{}{
+					"integerValue": int(mockAccessLevel),
+				},
+				"group": map[string]interface{}{
+					"id":       "gid://gitlab/Group/" + strconv.Itoa(g.ID),
+					"name":     g.Name,
+					"path":     g.Path,
+					"fullName": g.FullName,
+					"fullPath": g.FullPath,
+				},
+			}
+		}
+
+		next := offset + len(groups)
+		data["currentUser"] = map[string]interface{}{
+			"id":             "gid://gitlab/User/" + strconv.Itoa(user.ID),
+			"username":       user.Username,
+			"name":           user.Name,
+			"state":          user.State,
+			"bot":            user.Bot,
+			"publicEmail":    user.PublicEmail,
+			"webUrl":         user.WebURL,
+			"avatarUrl":      user.AvatarURL,
+			"lastActivityOn": formatISOTime(user.LastActivityOn),
+			"groupMemberships": map[string]interface{}{
+				"pageInfo": map[string]interface{}{
+					"hasNextPage": next < total,
+					"endCursor":   strconv.Itoa(next),
+				},
+				"nodes": nodes,
+			},
+		}
+
+		logger.Info("GraphQL request yielded result", "uid", uid, "after", offset, "first", size, "total", total)
+		respondDTO(w, result)
+	}
+
+	return http.HandlerFunc(handler)
+}
+
+func formatISOTime(t *gitlab.ISOTime) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return time.Time(*t).Format(time.DateOnly)
+}
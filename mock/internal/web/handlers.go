@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/gitlab-mock/internal/model"
 )
@@ -68,6 +69,100 @@ func MetaDataHandler(logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(handler)
 }
 
+func TokenHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		auth := parseToken(req)
+		if auth == "" {
+			logger.Info("Token request is missing authentication information")
+			respondError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		uid, err := dao.Tokens.FindUserIdentifier(auth)
+		if errors.Is(err, model.ErrNotFound) {
+			logger.Info("Token request with invalid authentication", "token", auth)
+			respondError(w, http.StatusUnauthorized, "invalid token")
+			return
+		} else if err != nil {
+			logger.Info("Token request can not be served properly", "err", err)
+			respondError(w, http.StatusInternalServerError, "token lookup failed")
+			return
+		}
+
+		logger.Info("Token request yielded result", "uid", uid)
+		respondDTO(w, model.PersonalAccessToken(uid))
+	}
+
+	return http.HandlerFunc(handler)
+}
+
+// JobHandler serves the job the presented CI job token belongs to.
+func JobHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		auth := parseToken(req)
+		if auth == "" {
+			logger.Info("Job request is missing authentication information")
+			respondError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		result, err := dao.Jobs.FindByToken(auth)
+		if errors.Is(err, model.ErrNotFound) {
+			logger.Info("Job request with invalid authentication", "token", auth)
+			respondError(w, http.StatusUnauthorized, "invalid token")
+			return
+		} else if err != nil {
+			logger.Info("Job request can not be served properly", "err", err)
+			respondError(w, http.StatusInternalServerError, "job lookup failed")
+			return
+		}
+
+		logger.Info("Job request yielded result", "job", result.ID)
+		respondDTO(w, result)
+	}
+
+	return http.HandlerFunc(handler)
+}
+
+// ProtectedBranchHandler serves the protection settings of the branch
+// identified by the request path. Any valid user token is accepted,
+// as the mock has no concept of project permissions.
+func ProtectedBranchHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		auth := parseToken(req)
+		if auth == "" {
+			logger.Info("Protected branch request is missing authentication information")
+			respondError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		if _, err := dao.Tokens.FindUserIdentifier(auth); err != nil {
+			logger.Info("Protected branch request with invalid authentication", "token", auth)
+			respondError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		pid, err := strconv.Atoi(req.PathValue("id"))
+		if err != nil {
+			respondError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		name := req.PathValue("name")
+		result := model.ProtectedBranch(pid, name)
+		if result == nil {
+			logger.Info("Protected branch request yielded no result", "project", pid, "branch", name)
+			respondError(w, http.StatusNotFound, "protected branch not found")
+			return
+		}
+
+		logger.Info("Protected branch request yielded result", "project", pid, "branch", name)
+		respondDTO(w, result)
+	}
+
+	return http.HandlerFunc(handler)
+}
+
 func MeHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
 	handler := func(w http.ResponseWriter, req *http.Request) {
 		auth := parseToken(req)
@@ -99,6 +194,43 @@ func MeHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(handler)
 }
 
+// UserHandler serves the user record identified by the request path.
+// Any valid token is accepted, as the mock has no concept of administrators.
+func UserHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		auth := parseToken(req)
+		if auth == "" {
+			logger.Info("User request is missing authentication information")
+			respondError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		if _, err := dao.Tokens.FindUserIdentifier(auth); err != nil {
+			logger.Info("User request with invalid authentication", "token", auth)
+			respondError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		uid, err := strconv.Atoi(req.PathValue("id"))
+		if err != nil {
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+
+		result, err := dao.Users.FindByIdentifier(uid)
+		if err != nil {
+			logger.Info("User request yielded no result", "uid", uid, "err", err)
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+
+		logger.Info("User request yielded result", "uid", uid)
+		respondDTO(w, result)
+	}
+
+	return http.HandlerFunc(handler)
+}
+
 func GroupsHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
 	handler := func(w http.ResponseWriter, req *http.Request) {
 		auth := parseToken(req)
@@ -117,6 +249,15 @@ func GroupsHandler(dao *model.DataAccess, logger *slog.Logger) http.Handler {
 			respondError(w, http.StatusInternalServerError, "token lookup failed")
 		}
 
+		if sudo := req.Header.Get(HeaderSudo); sudo != "" {
+			// impersonation is granted to every token
+			uid, err = strconv.Atoi(sudo)
+			if err != nil {
+				respondError(w, http.StatusForbidden, "invalid sudo user")
+				return
+			}
+		}
+
 		page, size := parseOffsetPagination(req)
 		offset := size * (page - 1)
 
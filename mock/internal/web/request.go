@@ -10,6 +10,8 @@ import (
 const (
 	HeaderAuthorization = "Authorization"
 	HeaderPrivateToken  = "PRIVATE-TOKEN"
+	HeaderJobToken      = "JOB-TOKEN"
+	HeaderSudo          = "Sudo"
 
 	QueryParamPagination = "pagination"
 	QueryParamOrder      = "order_by"
@@ -62,6 +64,11 @@ func parseToken(r *http.Request) string {
 		return token
 	}
 
+	token = r.Header.Get(HeaderJobToken)
+	if token != "" {
+		return token
+	}
+
 	auth := r.Header.Get(HeaderAuthorization)
 	if auth == "" {
 		return auth
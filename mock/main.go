@@ -22,6 +22,7 @@ func run(o, e io.Writer, argv ...string) int {
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	groups := fs.Uint64("mock.feature-groups", 50, "Number of mock feature groups to create")
 	prefix := fs.String("mock.token-prefix", "glpat-", "Prefix to use for generated authentication tokens")
+	jobPrefix := fs.String("mock.job-token-prefix", "glcbt-", "Prefix to use for generated CI job tokens")
 	listen := fs.String("web.listen-address", ":8080", "Addresses to listen for incoming HTTP requests")
 	rtTime := fs.Duration("rate-limit.interval", 1*time.Minute, "Fake rate limit interval to report to clients")
 	rtSize := fs.Int64("rate-limit.quota", 100, "Fake rate limit quota to report to clients")
@@ -45,8 +46,9 @@ func run(o, e io.Writer, argv ...string) int {
 	}
 
 	mocks := &dao.Mocks{
-		TokenPrefix: *prefix,
-		GroupCount:  *groups,
+		TokenPrefix:    *prefix,
+		JobTokenPrefix: *jobPrefix,
+		GroupCount:     *groups,
 	}
 	if err := mocks.Create(data); err != nil {
 		logger.Error("Mock seeding failed", "err", err)
@@ -56,7 +58,12 @@ func run(o, e io.Writer, argv ...string) int {
 	router := http.NewServeMux()
 	router.Handle("/", web.NotFoundHandler(logger))
 	router.Handle("/api/v4/user", web.MeHandler(data, logger))
+	router.Handle("/api/v4/personal_access_tokens/self", web.TokenHandler(data, logger))
+	router.Handle("/api/v4/users/{id}", web.UserHandler(data, logger))
 	router.Handle("/api/v4/groups", web.GroupsHandler(data, logger))
+	router.Handle("/api/v4/job", web.JobHandler(data, logger))
+	router.Handle("/api/v4/projects/{id}/protected_branches/{name}", web.ProtectedBranchHandler(data, logger))
+	router.Handle("/api/graphql", web.GraphQLHandler(data, logger))
 	router.Handle("/api/v4/version", web.VersionHandler(logger))
 	router.Handle("/api/v4/metadata", web.MetaDataHandler(logger))
 
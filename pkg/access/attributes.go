@@ -0,0 +1,54 @@
+package access
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+)
+
+// NewRequireAttributesAuthorizer returns an [userauthz.Authorizer] instance
+// which requires EACH of the given custom attributes to be present with a
+// value matching at least one of the associated patterns.
+func NewRequireAttributesAuthorizer(attributes map[string][]*regexp.Regexp) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		for key, patterns := range attributes {
+			have, ok := extra[GitlabKeyNamespace+key]
+			if !ok || len(have) == 0 {
+				return fmt.Errorf("attribute %q is missing", key)
+			}
+
+			if !slices.ContainsFunc(patterns, func(p *regexp.Regexp) bool {
+				return p.MatchString(have[0])
+			}) {
+				return fmt.Errorf("attribute %q has value %q which is not permitted", key, have[0])
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewRejectAttributesAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users with at least one of the given custom attributes
+// having a value matching any of the associated patterns. Missing
+// attributes are not rejected.
+func NewRejectAttributesAuthorizer(attributes map[string][]*regexp.Regexp) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		for key, patterns := range attributes {
+			have, ok := extra[GitlabKeyNamespace+key]
+			if !ok || len(have) == 0 {
+				continue
+			}
+
+			for _, p := range patterns {
+				if p.MatchString(have[0]) {
+					return fmt.Errorf("attribute %q has rejected value %q", key, have[0])
+				}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package access_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestCustomAttributeAuthorizers(t *testing.T) {
+	compile := func(attributes map[string][]string) map[string][]*regexp.Regexp {
+		t.Helper()
+
+		result := make(map[string][]*regexp.Regexp, len(attributes))
+		for k, v := range attributes {
+			patterns, err := access.CompilePatterns(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result[k] = patterns
+		}
+
+		return result
+	}
+	info := access.UserInfo(&gitlab.User{
+		ID:       1,
+		Username: "jdoe",
+		CustomAttributes: []*gitlab.CustomAttribute{
+			{Key: "employment", Value: "contractor"},
+			{Key: "cost-center", Value: "eng-42"},
+		},
+	}, nil, access.UserInfoOptions{})
+
+	tests := map[string]struct {
+		subject userauthz.Authorizer
+		want    bool
+	}{
+		"require": {access.NewRequireAttributesAuthorizer(compile(map[string][]string{
+			"employment": {"employee", "contractor"}, "cost-center": {"eng-*"},
+		})), true},
+		"require_mismatch": {access.NewRequireAttributesAuthorizer(compile(map[string][]string{
+			"employment": {"employee"},
+		})), false},
+		"require_missing": {access.NewRequireAttributesAuthorizer(compile(map[string][]string{
+			"clearance": {"*"},
+		})), false},
+		"reject": {access.NewRejectAttributesAuthorizer(compile(map[string][]string{
+			"employment": {"contractor"},
+		})), false},
+		"reject_mismatch": {access.NewRejectAttributesAuthorizer(compile(map[string][]string{
+			"employment": {"intern"}, "cost-center": {"/ops-.*/"},
+		})), true},
+		"reject_missing": {access.NewRejectAttributesAuthorizer(compile(map[string][]string{
+			"clearance": {"none"},
+		})), true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package access
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+
 	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
 	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
 )
 
+var errStateUnknown = errors.New("account state is unknown")
+
 // NewRequire2FAAuthorizer returns an [userauthz.Authorizer]
 // which rejects users whose extra values DO NOT contain [Attribute2fa]
 func NewRequire2FAAuthorizer() userauthz.Authorizer {
@@ -35,6 +44,92 @@ func NewRejectDormantAuthorizer() userauthz.Authorizer {
 	return userinfo.RejectExtra(GitlabAttributesKey, AttributeDormant)
 }
 
+// NewRequireAdminAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values DO NOT contain [AttributeAdmin]
+func NewRequireAdminAuthorizer() userauthz.Authorizer {
+	return userinfo.RequireExtra(GitlabAttributesKey, AttributeAdmin)
+}
+
+// NewRejectAdminAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values contain [AttributeAdmin]
+func NewRejectAdminAuthorizer() userauthz.Authorizer {
+	return userinfo.RejectExtra(GitlabAttributesKey, AttributeAdmin)
+}
+
+// NewRequireAuditorAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values DO NOT contain [AttributeAuditor]
+func NewRequireAuditorAuthorizer() userauthz.Authorizer {
+	return userinfo.RequireExtra(GitlabAttributesKey, AttributeAuditor)
+}
+
+// NewRejectAuditorAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values contain [AttributeAuditor]
+func NewRejectAuditorAuthorizer() userauthz.Authorizer {
+	return userinfo.RejectExtra(GitlabAttributesKey, AttributeAuditor)
+}
+
+// NewRequireExternalAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values DO NOT contain [AttributeExternal]
+func NewRequireExternalAuthorizer() userauthz.Authorizer {
+	return userinfo.RequireExtra(GitlabAttributesKey, AttributeExternal)
+}
+
+// NewRejectExternalAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values contain [AttributeExternal]
+func NewRejectExternalAuthorizer() userauthz.Authorizer {
+	return userinfo.RejectExtra(GitlabAttributesKey, AttributeExternal)
+}
+
+// NewRequirePrivateAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values DO NOT contain [AttributePrivate]
+func NewRequirePrivateAuthorizer() userauthz.Authorizer {
+	return userinfo.RequireExtra(GitlabAttributesKey, AttributePrivate)
+}
+
+// NewRejectPrivateAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values contain [AttributePrivate]
+func NewRejectPrivateAuthorizer() userauthz.Authorizer {
+	return userinfo.RejectExtra(GitlabAttributesKey, AttributePrivate)
+}
+
+// NewRequireStateAuthorizer returns an [userauthz.Authorizer] instance
+// which requires the account of a user to be in the given state
+// (e.g. active). Users whose state is unknown are rejected.
+func NewRequireStateAuthorizer(state string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, ok := extra[GitlabStateKey]
+		if !ok || len(have) == 0 {
+			return errStateUnknown
+		}
+
+		if !strings.EqualFold(have[0], state) {
+			return fmt.Errorf("account state %q is not %q", have[0], state)
+		}
+
+		return nil
+	})
+}
+
+// NewRejectStatesAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users whose account is in any of the given states.
+// Users whose state is unknown are not rejected.
+func NewRejectStatesAuthorizer(states []string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, ok := extra[GitlabStateKey]
+		if !ok || len(have) == 0 {
+			return nil
+		}
+
+		for _, s := range states {
+			if strings.EqualFold(have[0], s) {
+				return fmt.Errorf("account state %q is rejected", have[0])
+			}
+		}
+
+		return nil
+	})
+}
+
 // NewRequireUsersAuthorizer returns an [userauthz.Authorizer] instance
 // which requires a user to be named in the given list.
 func NewRequireUsersAuthorizer(users []string) userauthz.Authorizer {
@@ -47,6 +142,31 @@ func NewRequireGroupsAuthorizer(groups []string) userauthz.Authorizer {
 	return userinfo.RequireAllGroups(groups)
 }
 
+// NewRequireAnyGroupsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires a user to be a member of at least ONE of the given groups.
+func NewRequireAnyGroupsAuthorizer(groups []string) userauthz.Authorizer {
+	return NewRequireMinGroupsAuthorizer(1, groups)
+}
+
+// NewRequireMinGroupsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires a user to be a member of at least n of the given groups.
+func NewRequireMinGroupsAuthorizer(n int, groups []string) userauthz.Authorizer {
+	return infoAuthorizer(func(u user.Info) error {
+		have := 0
+		for _, g := range u.GetGroups() {
+			if slices.Contains(groups, g) {
+				have++
+			}
+		}
+
+		if have < n {
+			return fmt.Errorf("member of %d instead of %d required groups", have, n)
+		}
+
+		return nil
+	})
+}
+
 // NewRejectUsersAuthorizer returns an [userauthz.Authorizer] instance
 // which rejects a user if named in the given list.
 func NewRejectUsersAuthorizer(users []string) userauthz.Authorizer {
@@ -0,0 +1,116 @@
+package access_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestAttributeAuthorizers(t *testing.T) {
+	confirmed := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+	regular := &gitlab.User{ID: 1, Username: "jdoe", ConfirmedAt: &confirmed}
+	special := &gitlab.User{ID: 2, Username: "root", ConfirmedAt: &confirmed,
+		IsAdmin: true, IsAuditor: true, External: true, PrivateProfile: true}
+
+	tests := map[string]struct {
+		subject     userauthz.Authorizer
+		wantRegular bool
+		wantSpecial bool
+	}{
+		"require_admin":    {access.NewRequireAdminAuthorizer(), false, true},
+		"reject_admin":     {access.NewRejectAdminAuthorizer(), true, false},
+		"require_auditor":  {access.NewRequireAuditorAuthorizer(), false, true},
+		"reject_auditor":   {access.NewRejectAuditorAuthorizer(), true, false},
+		"require_external": {access.NewRequireExternalAuthorizer(), false, true},
+		"reject_external":  {access.NewRejectExternalAuthorizer(), true, false},
+		"require_private":  {access.NewRequirePrivateAuthorizer(), false, true},
+		"reject_private":   {access.NewRejectPrivateAuthorizer(), true, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for user, want := range map[*gitlab.User]bool{regular: tt.wantRegular, special: tt.wantSpecial} {
+				info := access.UserInfo(user, nil, access.UserInfoOptions{})
+				got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+				if (got == userauthz.DecisionAllow) != want {
+					t.Errorf("Authorize(%s) = %q; want allowed: %v", user.Username, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupQuorumAuthorizers(t *testing.T) {
+	info := access.UserInfo(&gitlab.User{ID: 1, Username: "jdoe"}, []*gitlab.Group{
+		{FullPath: "team/alpha"}, {FullPath: "team/beta"}, {FullPath: "core"},
+	}, access.UserInfoOptions{})
+
+	tests := map[string]struct {
+		subject userauthz.Authorizer
+		want    bool
+	}{
+		"any":          {access.NewRequireAnyGroupsAuthorizer([]string{"team:gamma", "team:beta"}), true},
+		"any_missing":  {access.NewRequireAnyGroupsAuthorizer([]string{"team:gamma", "team:delta"}), false},
+		"min":          {access.NewRequireMinGroupsAuthorizer(2, []string{"team:alpha", "team:beta", "team:gamma"}), true},
+		"min_exceeded": {access.NewRequireMinGroupsAuthorizer(3, []string{"team:alpha", "team:beta", "team:gamma"}), false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateAuthorizers(t *testing.T) {
+	states := map[string]string{
+		"active":                   "",
+		"blocked":                  access.GroupBlocked,
+		"deactivated":              access.GroupDeactivated,
+		"banned":                   access.GroupBanned,
+		"ldap_blocked":             access.GroupLDAPBlocked,
+		"blocked_pending_approval": access.GroupPendingApproval,
+	}
+	requireActive := access.NewRequireStateAuthorizer("active")
+	rejectBlocked := access.NewRejectStatesAuthorizer([]string{"blocked", "ldap_blocked"})
+
+	for state, group := range states {
+		t.Run(state, func(t *testing.T) {
+			info := access.UserInfo(&gitlab.User{ID: 1, Username: "jdoe", State: state}, nil,
+				access.UserInfoOptions{AttributesAsGroups: true})
+
+			if got := slices.Contains(info.Groups, group); group != "" && !got {
+				t.Errorf("Groups = %v; want %q", info.Groups, group)
+			}
+
+			got := requireActive.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if want := state == "active"; (got == userauthz.DecisionAllow) != want {
+				t.Errorf("require_state: active = %q; want allowed: %v", got, want)
+			}
+
+			got = rejectBlocked.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if want := state != "blocked" && state != "ldap_blocked"; (got == userauthz.DecisionAllow) != want {
+				t.Errorf("reject_states = %q; want allowed: %v", got, want)
+			}
+		})
+	}
+
+	unknown := userinfo.NewV1UserInfo(access.UserInfo(&gitlab.User{ID: 1, Username: "jdoe"}, nil, access.UserInfoOptions{}))
+	if got := requireActive.Authorize(context.Background(), unknown); got == userauthz.DecisionAllow {
+		t.Error("require_state allows unknown state")
+	}
+	if got := rejectBlocked.Authorize(context.Background(), unknown); got != userauthz.DecisionAllow {
+		t.Errorf("reject_states = %q for unknown state; want allowed", got)
+	}
+}
@@ -7,8 +7,63 @@ const (
 	// GitlabAttributesKey is the key used in a user's "extra" to specify
 	// the Gitlab specific account attributes
 	GitlabAttributesKey = GitlabKeyNamespace + "user-attributes"
+	// GitlabStateKey is the key used in a user's "extra" to specify
+	// the state (active, blocked, banned, ...) of the Gitlab account
+	GitlabStateKey = GitlabKeyNamespace + "user-state"
+	// GitlabBackendKey is the key used in a user's "extra" to specify
+	// the name of the Gitlab instance the user originates from
+	GitlabBackendKey = GitlabKeyNamespace + "backend"
+	// GitlabTokenTypeKey is the key used in a user's "extra" to specify
+	// the authentication scheme (private, oauth, job) of the presented token
+	GitlabTokenTypeKey = GitlabKeyNamespace + "token-type"
+	// GitlabTokenIDKey is the key used in a user's "extra" to specify
+	// the ID of the token presented for authentication
+	GitlabTokenIDKey = GitlabKeyNamespace + "token-id"
+	// GitlabTokenNameKey is the key used in a user's "extra" to specify
+	// the name of the token presented for authentication
+	GitlabTokenNameKey = GitlabKeyNamespace + "token-name"
+	// GitlabTokenScopesKey is the key used in a user's "extra" to specify
+	// the scopes of the token presented for authentication
+	GitlabTokenScopesKey = GitlabKeyNamespace + "token-scopes"
+	// GitlabTokenExpiresKey is the key used in a user's "extra" to specify
+	// the expiration date (YYYY-MM-DD) of the token presented for authentication
+	GitlabTokenExpiresKey = GitlabKeyNamespace + "token-expires-at"
+	// GitlabTokenCreatedKey is the key used in a user's "extra" to specify
+	// the creation time (RFC 3339) of the token presented for authentication
+	GitlabTokenCreatedKey = GitlabKeyNamespace + "token-created-at"
+	// GitlabTokenAttributesKey is the key used in a user's "extra" to specify
+	// the attributes of the token presented for authentication
+	GitlabTokenAttributesKey = GitlabKeyNamespace + "token-attributes"
+	// GitlabPipelineKeyNamespace is the key namespace used in a user's "extra"
+	// to represent the details of CI pipelines. It is distinct from
+	// [GitlabKeyNamespace] to keep custom attributes from posing as them.
+	GitlabPipelineKeyNamespace = "pipeline.gitlab-authn.kubernetes.io/"
+	// GitlabJobIDKey is the key used in a user's "extra" to specify
+	// the ID of the CI job the presented job token belongs to
+	GitlabJobIDKey = GitlabPipelineKeyNamespace + "job-id"
+	// GitlabPipelineIDKey is the key used in a user's "extra" to specify
+	// the ID of the CI pipeline the presented job token belongs to
+	GitlabPipelineIDKey = GitlabPipelineKeyNamespace + "pipeline-id"
+	// GitlabProjectKey is the key used in a user's "extra" to specify
+	// the full path of the project running the CI pipeline
+	GitlabProjectKey = GitlabPipelineKeyNamespace + "project"
+	// GitlabRefKey is the key used in a user's "extra" to specify
+	// the branch or tag the CI pipeline runs for
+	GitlabRefKey = GitlabPipelineKeyNamespace + "ref"
+	// GitlabEnvironmentKey is the key used in a user's "extra" to specify
+	// the deployment environment of the CI job
+	GitlabEnvironmentKey = GitlabPipelineKeyNamespace + "environment"
+	// GitlabTriggerKey is the key used in a user's "extra" to specify
+	// the username of the user who triggered the CI job
+	GitlabTriggerKey = GitlabPipelineKeyNamespace + "triggered-by"
 	// GitlabGroup is the group prefix for groups based on user attributes
 	GitlabGroup = "gitlab"
+	// PipelinePrefix is the prefix of usernames, UIDs,
+	// and namespace groups of CI pipeline identities
+	PipelinePrefix = "pipeline:"
+	// EnvironmentPrefix is the prefix of groups based
+	// on the deployment environment of CI jobs
+	EnvironmentPrefix = "environment:"
 )
 
 const (
@@ -39,6 +94,34 @@ const (
 	// AttributeDormant is the extra value added to authentication objects
 	// when the user has not shown any activity for an extended period of time
 	AttributeDormant = "dormant"
+	// AttributeBlocked is the extra value added to authentication objects
+	// when the user account has been blocked by an administrator
+	AttributeBlocked = "blocked"
+	// AttributeDeactivated is the extra value added to authentication objects
+	// when the user account has been deactivated
+	AttributeDeactivated = "deactivated"
+	// AttributeBanned is the extra value added to authentication objects
+	// when the user account has been banned
+	AttributeBanned = "banned"
+	// AttributeLDAPBlocked is the extra value added to authentication objects
+	// when the user account has been blocked by the LDAP synchronization
+	AttributeLDAPBlocked = "ldap_blocked"
+	// AttributePendingApproval is the extra value added to authentication objects
+	// when the user account awaits approval by an administrator
+	AttributePendingApproval = "blocked_pending_approval"
+	// AttributeStale is the extra value added to authentication objects
+	// when the information has been served from an expired cache entry
+	// due to Gitlab being unavailable
+	AttributeStale = "stale"
+	// AttributePipeline is the extra value added to authentication objects
+	// when the identity is a CI pipeline authenticated by a job token
+	AttributePipeline = "pipeline"
+	// AttributeProtected is the extra value added to authentication objects
+	// when the CI pipeline runs for a protected branch or tag
+	AttributeProtected = "protected"
+	// AttributeImpersonation is the token attribute added to authentication
+	// objects when the presented token is an impersonation token
+	AttributeImpersonation = "impersonation"
 )
 
 const (
@@ -69,4 +152,25 @@ const (
 	// GroupDormant is the extra value added to authentication objects
 	// when the user has not shown any activity for an extended period of time
 	GroupDormant = GitlabGroup + ":" + AttributeDormant
+	// GroupBlocked is the pseudo group added to authentication objects
+	// when the user account has been blocked by an administrator
+	GroupBlocked = GitlabGroup + ":" + AttributeBlocked
+	// GroupDeactivated is the pseudo group added to authentication objects
+	// when the user account has been deactivated
+	GroupDeactivated = GitlabGroup + ":" + AttributeDeactivated
+	// GroupBanned is the pseudo group added to authentication objects
+	// when the user account has been banned
+	GroupBanned = GitlabGroup + ":" + AttributeBanned
+	// GroupLDAPBlocked is the pseudo group added to authentication objects
+	// when the user account has been blocked by the LDAP synchronization
+	GroupLDAPBlocked = GitlabGroup + ":" + AttributeLDAPBlocked
+	// GroupPendingApproval is the pseudo group added to authentication objects
+	// when the user account awaits approval by an administrator
+	GroupPendingApproval = GitlabGroup + ":" + AttributePendingApproval
+	// GroupPipeline is the pseudo group added to authentication objects
+	// when the identity is a CI pipeline authenticated by a job token
+	GroupPipeline = GitlabGroup + ":" + AttributePipeline
+	// GroupProtected is the pseudo group added to authentication objects
+	// when the CI pipeline runs for a protected branch or tag
+	GroupProtected = GitlabGroup + ":" + AttributeProtected
 )
@@ -1,6 +1,7 @@
 package access
 
 import (
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,10 @@ type UserInfoOptions struct {
 	AttributesAsGroups bool
 	DormantTimeout     time.Duration
 	Now                func() time.Time
+	// Attributes (e.g. [AttributeAdmin]) the identity source is
+	// unable to determine; they are neither reported as extra
+	// values nor as groups.
+	UnknownAttributes []string
 }
 
 func UserInfo(user *gitlab.User, groups []*gitlab.Group, opts UserInfoOptions) authentication.UserInfo {
@@ -32,7 +37,9 @@ func UserInfo(user *gitlab.User, groups []*gitlab.Group, opts UserInfoOptions) a
 	}
 
 	if opts.AttributesAsGroups {
-		agids := userAttributeGroups(user, dormant)
+		agids := slices.DeleteFunc(userAttributeGroups(user, dormant), func(g string) bool {
+			return slices.Contains(opts.UnknownAttributes, strings.TrimPrefix(g, GitlabGroup+":"))
+		})
 		gids = make([]string, len(groups), len(groups)+len(agids))
 		gids = append(gids, agids...)
 	} else {
@@ -44,6 +51,9 @@ func UserInfo(user *gitlab.User, groups []*gitlab.Group, opts UserInfoOptions) a
 	}
 
 	extra := userAttributeExtra(user, dormant)
+	extra[GitlabAttributesKey] = slices.DeleteFunc(extra[GitlabAttributesKey], func(a string) bool {
+		return slices.Contains(opts.UnknownAttributes, a)
+	})
 	info := authentication.UserInfo{
 		Username: user.Username,
 		UID:      strconv.FormatInt(int64(user.ID), 10),
@@ -54,6 +64,110 @@ func UserInfo(user *gitlab.User, groups []*gitlab.Group, opts UserInfoOptions) a
 	return info
 }
 
+// StaleUserInfo returns a copy of the given user information
+// whose extra values contain [AttributeStale].
+func StaleUserInfo(info authentication.UserInfo) authentication.UserInfo {
+	extra := make(map[string]authentication.ExtraValue, len(info.Extra))
+	for k, v := range info.Extra {
+		extra[k] = v
+	}
+
+	attrs := make([]string, len(extra[GitlabAttributesKey]), len(extra[GitlabAttributesKey])+1)
+	copy(attrs, extra[GitlabAttributesKey])
+	extra[GitlabAttributesKey] = append(attrs, AttributeStale)
+	info.Extra = extra
+
+	return info
+}
+
+// TokenInfo describes the token presented for authentication.
+type TokenInfo struct {
+	// Authentication scheme of the token
+	Type string
+	// Details as reported by Gitlab; nil if unknown
+	Details *gitlab.PersonalAccessToken
+	// Whether the token has been issued to impersonate the user;
+	// nil if unknown
+	Impersonation *bool
+}
+
+// TokenUserInfo returns a copy of the given user information
+// whose extra values contain the details of the given token.
+func TokenUserInfo(info authentication.UserInfo, token TokenInfo) authentication.UserInfo {
+	extra := make(map[string]authentication.ExtraValue, len(info.Extra)+7)
+	for k, v := range info.Extra {
+		extra[k] = v
+	}
+
+	if token.Type != "" {
+		extra[GitlabTokenTypeKey] = []string{token.Type}
+	}
+
+	if d := token.Details; d != nil {
+		extra[GitlabTokenIDKey] = []string{strconv.FormatInt(int64(d.ID), 10)}
+		extra[GitlabTokenNameKey] = []string{d.Name}
+		extra[GitlabTokenScopesKey] = d.Scopes
+		if d.ExpiresAt != nil {
+			extra[GitlabTokenExpiresKey] = []string{d.ExpiresAt.String()}
+		}
+		if d.CreatedAt != nil {
+			extra[GitlabTokenCreatedKey] = []string{d.CreatedAt.UTC().Format(time.RFC3339)}
+		}
+
+		if token.Impersonation != nil {
+			attrs := []string{}
+			if *token.Impersonation {
+				attrs = append(attrs, AttributeImpersonation)
+			}
+			extra[GitlabTokenAttributesKey] = attrs
+		}
+	}
+
+	info.Extra = extra
+
+	return info
+}
+
+// NamespacedUserInfo returns a copy of the given user information
+// whose username, UID, and groups are prefixed with the namespace.
+// The name of the originating backend is recorded in the extra values.
+func NamespacedUserInfo(info authentication.UserInfo, namespace, backend string) authentication.UserInfo {
+	groups := make([]string, len(info.Groups))
+	for i, g := range info.Groups {
+		groups[i] = namespace + g
+	}
+
+	extra := make(map[string]authentication.ExtraValue, len(info.Extra)+1)
+	for k, v := range info.Extra {
+		extra[k] = v
+	}
+	if backend != "" {
+		extra[GitlabBackendKey] = []string{backend}
+	}
+
+	info.Username = namespace + info.Username
+	info.UID = namespace + info.UID
+	info.Groups = groups
+	info.Extra = extra
+
+	return info
+}
+
+// reservedKeys are the extra keys populated by kubernetes-gitlab-authn,
+// which custom attributes are therefore not permitted to occupy.
+var reservedKeys = []string{
+	GitlabAttributesKey,
+	GitlabStateKey,
+	GitlabBackendKey,
+	GitlabTokenTypeKey,
+	GitlabTokenIDKey,
+	GitlabTokenNameKey,
+	GitlabTokenScopesKey,
+	GitlabTokenExpiresKey,
+	GitlabTokenCreatedKey,
+	GitlabTokenAttributesKey,
+}
+
 func userAttributeGroups(user *gitlab.User, dormant bool) []string {
 	groups := make([]string, 0, 5)
 
@@ -84,6 +198,9 @@ func userAttributeGroups(user *gitlab.User, dormant bool) []string {
 	if dormant {
 		groups = append(groups, GroupDormant)
 	}
+	if attr := stateAttribute(user.State); attr != "" {
+		groups = append(groups, GitlabGroup+":"+attr)
+	}
 
 	return groups
 }
@@ -117,14 +234,44 @@ func userAttributeExtra(user *gitlab.User, dormant bool) map[string]authenticati
 	if dormant {
 		attrs = append(attrs, AttributeDormant)
 	}
+	if attr := stateAttribute(user.State); attr != "" {
+		attrs = append(attrs, attr)
+	}
 
 	extra := map[string]authentication.ExtraValue{
 		GitlabAttributesKey: attrs,
 	}
+	if user.State != "" {
+		extra[GitlabStateKey] = []string{strings.ToLower(user.State)}
+	}
 
 	for _, attr := range user.CustomAttributes {
-		extra[GitlabKeyNamespace+attr.Key] = []string{attr.Value}
+		key := GitlabKeyNamespace + attr.Key
+		if slices.Contains(reservedKeys, key) {
+			continue
+		}
+
+		extra[key] = []string{attr.Value}
 	}
 
 	return extra
 }
+
+// stateAttribute returns the attribute representing the given
+// account state or an empty string for active (or unknown) accounts.
+func stateAttribute(state string) string {
+	switch strings.ToLower(state) {
+	case AttributeBlocked:
+		return AttributeBlocked
+	case AttributeDeactivated:
+		return AttributeDeactivated
+	case AttributeBanned:
+		return AttributeBanned
+	case AttributeLDAPBlocked:
+		return AttributeLDAPBlocked
+	case AttributePendingApproval:
+		return AttributePendingApproval
+	}
+
+	return ""
+}
@@ -0,0 +1,56 @@
+package access_test
+
+import (
+	"slices"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestUserInfoUnknownAttributes(t *testing.T) {
+	// unconfirmed as far as the user object is concerned
+	user := &gitlab.User{ID: 1, Username: "jdoe", Bot: true}
+	got := access.UserInfo(user, nil, access.UserInfoOptions{
+		AttributesAsGroups: true,
+		UnknownAttributes:  []string{access.AttributePristine},
+	})
+
+	if want := []string{access.AttributeBot}; !slices.Equal(got.Extra[access.GitlabAttributesKey], want) {
+		t.Errorf("attributes = %v; want %v", got.Extra[access.GitlabAttributesKey], want)
+	}
+
+	if want := []string{access.GroupBot}; !slices.Equal(got.Groups, want) {
+		t.Errorf("groups = %v; want %v", got.Groups, want)
+	}
+}
+
+func TestUserInfoReservedCustomAttributes(t *testing.T) {
+	user := &gitlab.User{ID: 1, Username: "jdoe", State: "blocked", CustomAttributes: []*gitlab.CustomAttribute{
+		{Key: "team", Value: "ops"},
+		{Key: "user-state", Value: "active"},
+		{Key: "user-attributes", Value: "admin"},
+		{Key: "token-scopes", Value: "read_user"},
+		{Key: "backend", Value: "corp"},
+	}}
+	got := access.UserInfo(user, nil, access.UserInfoOptions{})
+
+	if want := []string{"ops"}; !slices.Equal(got.Extra[access.GitlabKeyNamespace+"team"], want) {
+		t.Errorf("team = %v; want %v", got.Extra[access.GitlabKeyNamespace+"team"], want)
+	}
+
+	if want := []string{"blocked"}; !slices.Equal(got.Extra[access.GitlabStateKey], want) {
+		t.Errorf("state = %v; want %v", got.Extra[access.GitlabStateKey], want)
+	}
+
+	if slices.Contains(got.Extra[access.GitlabAttributesKey], access.AttributeAdmin) {
+		t.Errorf("attributes = %v; want no %s", got.Extra[access.GitlabAttributesKey], access.AttributeAdmin)
+	}
+
+	for _, key := range []string{access.GitlabTokenScopesKey, access.GitlabBackendKey} {
+		if v, ok := got.Extra[key]; ok {
+			t.Errorf("%s = %v; want unset", key, v)
+		}
+	}
+}
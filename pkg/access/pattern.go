@@ -0,0 +1,134 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+)
+
+// CompilePattern converts the given pattern into an anchored regular
+// expression. Patterns enclosed in slashes (e.g. /^svc-[a-z]+$/) are
+// regular expressions, anything else is a glob where `*` matches any
+// sequence within a single `:`-separated segment, `**` matches across
+// segments, and `?` matches a single character other than `:`.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr := strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+		return regexp.Compile("^(?:" + expr + ")$")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^:]*")
+		case c == '?':
+			expr.WriteString("[^:]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// CompilePatterns compiles each of the given patterns
+// using [CompilePattern].
+func CompilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := CompilePattern(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+
+		result[i] = re
+	}
+
+	return result, nil
+}
+
+// infoAuthorizer is an [userauthz.Authorizer] evaluating
+// the name and groups of a user. The reason for a rejection
+// is used as decision.
+type infoAuthorizer func(u user.Info) error
+
+func (a infoAuthorizer) Authorize(_ context.Context, u user.Info) userauthz.Decision {
+	if err := a(u); err != nil {
+		return userauthz.Decision(err.Error())
+	}
+
+	return userauthz.DecisionAllow
+}
+
+// NewRequireUserPatternsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires the name of a user to match at least one of the given patterns.
+func NewRequireUserPatternsAuthorizer(patterns []*regexp.Regexp) userauthz.Authorizer {
+	return infoAuthorizer(func(u user.Info) error {
+		for _, p := range patterns {
+			if p.MatchString(u.GetName()) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("user %q matches none of the permitted patterns", u.GetName())
+	})
+}
+
+// NewRejectUserPatternsAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users whose name matches at least one of the given patterns.
+func NewRejectUserPatternsAuthorizer(patterns []*regexp.Regexp) userauthz.Authorizer {
+	return infoAuthorizer(func(u user.Info) error {
+		for _, p := range patterns {
+			if p.MatchString(u.GetName()) {
+				return fmt.Errorf("user %q matches rejected pattern %q", u.GetName(), p)
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewRequireGroupPatternsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires a user to be a member of at least one group matching
+// each of the given patterns.
+func NewRequireGroupPatternsAuthorizer(patterns []*regexp.Regexp) userauthz.Authorizer {
+	return infoAuthorizer(func(u user.Info) error {
+		groups := u.GetGroups()
+		for _, p := range patterns {
+			if !slices.ContainsFunc(groups, p.MatchString) {
+				return fmt.Errorf("no group matches pattern %q", p)
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewRejectGroupPatternsAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users with membership of at least one group matching
+// any of the given patterns.
+func NewRejectGroupPatternsAuthorizer(patterns []*regexp.Regexp) userauthz.Authorizer {
+	return infoAuthorizer(func(u user.Info) error {
+		for _, g := range u.GetGroups() {
+			for _, p := range patterns {
+				if p.MatchString(g) {
+					return fmt.Errorf("group %q matches rejected pattern %q", g, p)
+				}
+			}
+		}
+
+		return nil
+	})
+}
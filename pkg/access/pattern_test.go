@@ -0,0 +1,127 @@
+package access_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	authentication "k8s.io/api/authentication/v1"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := map[string]map[string]bool{
+		"platform:**": {
+			"platform:ops":        true,
+			"platform:ops:oncall": true,
+			"platform":            false,
+			"platforms:ops":       false,
+			"core:platform:ops":   false,
+		},
+		"platform:*": {
+			"platform:ops":        true,
+			"platform:ops:oncall": false,
+			"platform":            false,
+		},
+		"platform:*:oncall": {
+			"platform:ops:oncall":     true,
+			"platform:dev:oncall":     true,
+			"platform:ops:sre:oncall": false,
+		},
+		"platform:**:oncall": {
+			"platform:ops:oncall":     true,
+			"platform:ops:sre:oncall": true,
+			"platform:oncall":         false,
+		},
+		"team-?": {
+			"team-a":  true,
+			"team-ab": false,
+			"team-:":  false,
+		},
+		"svc-*": {
+			"svc-deploy":  true,
+			"svc-":        true,
+			"xsvc-deploy": false,
+		},
+		"a.b": {
+			"a.b": true,
+			"axb": false,
+		},
+		"/svc-[a-z]+/": {
+			"svc-deploy":    true,
+			"svc-deploy2":   false,
+			"my-svc-deploy": false,
+		},
+		"/platform|core/": {
+			"platform":     true,
+			"core":         true,
+			"platform:ops": false,
+		},
+	}
+
+	for pattern, names := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			re, err := access.CompilePattern(pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range names {
+				if got := re.MatchString(name); got != want {
+					t.Errorf("%q matches %q = %v; want %v", pattern, name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePatternsInvalid(t *testing.T) {
+	if _, err := access.CompilePatterns([]string{"svc-*", "/svc-(/"}); err == nil {
+		t.Error("CompilePatterns() = nil; want error")
+	}
+}
+
+func TestPatternAuthorizers(t *testing.T) {
+	compile := func(patterns ...string) []*regexp.Regexp {
+		t.Helper()
+
+		result, err := access.CompilePatterns(patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+	info := authentication.UserInfo{
+		Username: "svc-deploy",
+		Groups:   []string{"core", "platform:ops:oncall"},
+	}
+
+	tests := map[string]struct {
+		subject userauthz.Authorizer
+		want    bool
+	}{
+		"require_users":          {access.NewRequireUserPatternsAuthorizer(compile("jdoe", "svc-*")), true},
+		"require_users_mismatch": {access.NewRequireUserPatternsAuthorizer(compile("usr-*")), false},
+		"reject_users":           {access.NewRejectUserPatternsAuthorizer(compile("/svc-.+/")), false},
+		"reject_users_mismatch":  {access.NewRejectUserPatternsAuthorizer(compile("usr-*")), true},
+		"require_groups":         {access.NewRequireGroupPatternsAuthorizer(compile("platform:**", "core")), true},
+		"require_groups_missing": {access.NewRequireGroupPatternsAuthorizer(compile("platform:**", "releng:**")), false},
+		"require_groups_segment": {access.NewRequireGroupPatternsAuthorizer(compile("platform:*")), false},
+		"reject_groups":          {access.NewRejectGroupPatternsAuthorizer(compile("**:oncall")), false},
+		"reject_groups_mismatch": {access.NewRejectGroupPatternsAuthorizer(compile("platform:*")), true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
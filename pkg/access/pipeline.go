@@ -0,0 +1,147 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	authentication "k8s.io/api/authentication/v1"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+)
+
+var errNoPipeline = errors.New("identity is not a CI pipeline")
+
+// PipelineInfo describes the CI job a job token belongs to.
+type PipelineInfo struct {
+	// ID of the job
+	JobID int
+	// ID of the pipeline
+	PipelineID int
+	// Full path of the project (e.g. platform/deploy)
+	Project string
+	// Full path of the namespace the project resides in
+	Namespace string
+	// Branch or tag the pipeline runs for
+	Ref string
+	// Whether Ref is a protected branch or tag
+	Protected bool
+	// Name of the deployment environment; might be empty
+	Environment string
+	// Username of the user who triggered the job; might be empty
+	TriggeredBy string
+}
+
+// PipelineUserInfo returns the user information representing a CI pipeline.
+// The username is derived from the project path and ref
+// (e.g. pipeline:platform:deploy@main), the groups from the project
+// namespace (pipeline:platform), the ref protection ([GroupProtected]),
+// and the deployment environment (environment:production).
+// Every pipeline is a member of [GroupPipeline].
+func PipelineUserInfo(job PipelineInfo) authentication.UserInfo {
+	groups := make([]string, 0, 4)
+	groups = append(groups, GroupPipeline)
+	if job.Namespace != "" {
+		groups = append(groups, PipelinePrefix+strings.ReplaceAll(job.Namespace, "/", ":"))
+	}
+	if job.Protected {
+		groups = append(groups, GroupProtected)
+	}
+	if job.Environment != "" {
+		groups = append(groups, EnvironmentPrefix+strings.ReplaceAll(job.Environment, "/", ":"))
+	}
+
+	attrs := []string{AttributePipeline}
+	if job.Protected {
+		attrs = append(attrs, AttributeProtected)
+	}
+
+	extra := map[string]authentication.ExtraValue{
+		GitlabAttributesKey: attrs,
+		GitlabJobIDKey:      []string{strconv.Itoa(job.JobID)},
+		GitlabPipelineIDKey: []string{strconv.Itoa(job.PipelineID)},
+		GitlabProjectKey:    []string{job.Project},
+		GitlabRefKey:        []string{job.Ref},
+	}
+	if job.Environment != "" {
+		extra[GitlabEnvironmentKey] = []string{job.Environment}
+	}
+	if job.TriggeredBy != "" {
+		extra[GitlabTriggerKey] = []string{job.TriggeredBy}
+	}
+
+	info := authentication.UserInfo{
+		Username: PipelinePrefix + strings.ReplaceAll(job.Project, "/", ":") + "@" + job.Ref,
+		UID:      PipelinePrefix + strconv.Itoa(job.JobID),
+		Groups:   groups,
+		Extra:    extra,
+	}
+
+	return info
+}
+
+// pipelineDetail returns the value of the given key
+// from the extra values of a CI pipeline identity.
+func pipelineDetail(extra map[string][]string, key string) (string, error) {
+	if !slices.Contains(extra[GitlabAttributesKey], AttributePipeline) {
+		return "", errNoPipeline
+	}
+
+	have := extra[key]
+	if len(have) == 0 {
+		return "", errNoPipeline
+	}
+
+	return have[0], nil
+}
+
+// NewRequireProjectsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires a CI pipeline to run in one of the given projects
+// (full path, e.g. platform/deploy). Other identities are rejected.
+func NewRequireProjectsAuthorizer(projects []string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, err := pipelineDetail(extra, GitlabProjectKey)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(projects, have) {
+			return fmt.Errorf("project %q is not permitted", have)
+		}
+
+		return nil
+	})
+}
+
+// NewRequireRefsAuthorizer returns an [userauthz.Authorizer] instance
+// which requires a CI pipeline to run for one of the given branches
+// or tags. Other identities are rejected.
+func NewRequireRefsAuthorizer(refs []string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, err := pipelineDetail(extra, GitlabRefKey)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(refs, have) {
+			return fmt.Errorf("ref %q is not permitted", have)
+		}
+
+		return nil
+	})
+}
+
+// NewRequireProtectedRefAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values DO NOT contain [AttributeProtected]
+func NewRequireProtectedRefAuthorizer() userauthz.Authorizer {
+	return userinfo.RequireExtra(GitlabAttributesKey, AttributeProtected)
+}
+
+// NewRejectPipelineAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose extra values contain [AttributePipeline]
+func NewRejectPipelineAuthorizer() userauthz.Authorizer {
+	return userinfo.RejectExtra(GitlabAttributesKey, AttributePipeline)
+}
@@ -0,0 +1,75 @@
+package access_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	authentication "k8s.io/api/authentication/v1"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestPipelineUserInfo(t *testing.T) {
+	got := access.PipelineUserInfo(access.PipelineInfo{
+		JobID:       99,
+		Project:     "platform/deploy",
+		Namespace:   "platform",
+		Ref:         "main",
+		Protected:   true,
+		Environment: "review/app",
+	})
+
+	if want := "pipeline:platform:deploy@main"; got.Username != want {
+		t.Errorf("Username = %q; want %q", got.Username, want)
+	}
+
+	want := []string{access.GroupPipeline, "pipeline:platform", access.GroupProtected, "environment:review:app"}
+	if !slices.Equal(got.Groups, want) {
+		t.Errorf("Groups = %v; want %v", got.Groups, want)
+	}
+}
+
+func TestPipelineAuthorizers(t *testing.T) {
+	protected := access.PipelineUserInfo(access.PipelineInfo{Project: "platform/deploy", Ref: "main", Protected: true})
+	feature := access.PipelineUserInfo(access.PipelineInfo{Project: "platform/deploy", Ref: "feature"})
+	human := authentication.UserInfo{Username: "jdoe"}
+	// custom attributes named after the pipeline details
+	impostor := access.UserInfo(&gitlab.User{ID: 1, Username: "jdoe", CustomAttributes: []*gitlab.CustomAttribute{
+		{Key: "project", Value: "platform/deploy"},
+		{Key: "ref", Value: "main"},
+		{Key: "pipeline.gitlab-authn.kubernetes.io/project", Value: "platform/deploy"},
+	}}, nil, access.UserInfoOptions{})
+
+	tests := map[string]struct {
+		have    authentication.UserInfo
+		subject userauthz.Authorizer
+		want    bool
+	}{
+		"require_projects":          {protected, access.NewRequireProjectsAuthorizer([]string{"platform/deploy"}), true},
+		"require_projects_mismatch": {protected, access.NewRequireProjectsAuthorizer([]string{"platform/infra"}), false},
+		"require_projects_human":    {human, access.NewRequireProjectsAuthorizer([]string{"platform/deploy"}), false},
+		"require_refs":              {protected, access.NewRequireRefsAuthorizer([]string{"main", "release"}), true},
+		"require_refs_mismatch":     {feature, access.NewRequireRefsAuthorizer([]string{"main"}), false},
+		"require_protected":         {protected, access.NewRequireProtectedRefAuthorizer(), true},
+		"require_protected_missing": {feature, access.NewRequireProtectedRefAuthorizer(), false},
+		"require_projects_impostor": {impostor, access.NewRequireProjectsAuthorizer([]string{"platform/deploy"}), false},
+		"require_refs_impostor":     {impostor, access.NewRequireRefsAuthorizer([]string{"main"}), false},
+		"reject_pipeline":           {protected, access.NewRejectPipelineAuthorizer(), false},
+		"reject_pipeline_human":     {human, access.NewRejectPipelineAuthorizer(), true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(tt.have))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+)
+
+var (
+	errTokenUnknown       = errors.New("token details are unknown")
+	errTokenUnlimited     = errors.New("token does not expire")
+	errTokenImpersonation = errors.New("token is an impersonation token")
+)
+
+// extraAuthorizer is an [userauthz.Authorizer] evaluating the
+// extra values of a user. The reason for a rejection is used
+// as decision.
+type extraAuthorizer func(extra map[string][]string) error
+
+func (a extraAuthorizer) Authorize(_ context.Context, u user.Info) userauthz.Decision {
+	if err := a(u.GetExtra()); err != nil {
+		return userauthz.Decision(err.Error())
+	}
+
+	return userauthz.DecisionAllow
+}
+
+// NewRequireScopesAuthorizer returns an [userauthz.Authorizer] instance
+// which requires the token of a user to have ALL given scopes.
+// Users whose token has not been introspected are rejected.
+func NewRequireScopesAuthorizer(scopes []string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, ok := extra[GitlabTokenScopesKey]
+		if !ok {
+			return errTokenUnknown
+		}
+
+		for _, s := range scopes {
+			if !slices.Contains(have, s) {
+				return fmt.Errorf("token is missing scope %q", s)
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewRejectScopesAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users whose token has at least one of the given scopes.
+// Users whose token has not been introspected are rejected.
+func NewRejectScopesAuthorizer(scopes []string) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		have, ok := extra[GitlabTokenScopesKey]
+		if !ok {
+			return errTokenUnknown
+		}
+
+		for _, s := range scopes {
+			if slices.Contains(have, s) {
+				return fmt.Errorf("token has rejected scope %q", s)
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewTokenNamePatternAuthorizer returns an [userauthz.Authorizer] instance
+// which requires the name of the token to match the given pattern
+// (see [CompilePattern]).
+func NewTokenNamePatternAuthorizer(pattern *regexp.Regexp) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		name, ok := extra[GitlabTokenNameKey]
+		if !ok || len(name) == 0 {
+			return errTokenUnknown
+		}
+
+		if !pattern.MatchString(name[0]) {
+			return fmt.Errorf("token name does not match %q", pattern)
+		}
+
+		return nil
+	})
+}
+
+// NewMaxTokenLifetimeAuthorizer returns an [userauthz.Authorizer] instance
+// which rejects users whose token is valid for longer than the given
+// duration (measured from its creation) or does not expire at all.
+func NewMaxTokenLifetimeAuthorizer(lifetime time.Duration) userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		created, ok := extra[GitlabTokenCreatedKey]
+		if !ok || len(created) == 0 {
+			return errTokenUnknown
+		}
+
+		expires, ok := extra[GitlabTokenExpiresKey]
+		if !ok || len(expires) == 0 {
+			return errTokenUnlimited
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, created[0])
+		if err != nil {
+			return err
+		}
+
+		expiresAt, err := time.Parse(time.DateOnly, expires[0])
+		if err != nil {
+			return err
+		}
+
+		if expiresAt.Sub(createdAt) > lifetime {
+			return fmt.Errorf("token lifetime exceeds %v", lifetime)
+		}
+
+		return nil
+	})
+}
+
+// NewRejectImpersonationAuthorizer returns an [userauthz.Authorizer]
+// which rejects users whose token attributes contain [AttributeImpersonation].
+// Users whose token has not been checked for impersonation are rejected.
+func NewRejectImpersonationAuthorizer() userauthz.Authorizer {
+	return extraAuthorizer(func(extra map[string][]string) error {
+		attrs, ok := extra[GitlabTokenAttributesKey]
+		if !ok {
+			return errTokenUnknown
+		}
+
+		if slices.Contains(attrs, AttributeImpersonation) {
+			return errTokenImpersonation
+		}
+
+		return nil
+	})
+}
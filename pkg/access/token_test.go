@@ -0,0 +1,110 @@
+package access_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	authentication "k8s.io/api/authentication/v1"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+)
+
+func TestTokenAuthorizers(t *testing.T) {
+	created := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+	expires := gitlab.ISOTime(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	token := &gitlab.PersonalAccessToken{
+		ID:        42,
+		Name:      "k8s-prod",
+		Scopes:    []string{"read_user", "read_api"},
+		CreatedAt: &created,
+		ExpiresAt: &expires,
+	}
+	unlimited := *token
+	unlimited.ExpiresAt = nil
+	compile := func(pattern string) *regexp.Regexp {
+		t.Helper()
+
+		result, err := access.CompilePattern(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	tests := map[string]struct {
+		have    *gitlab.PersonalAccessToken
+		subject userauthz.Authorizer
+		want    bool
+	}{
+		"require_scopes":         {token, access.NewRequireScopesAuthorizer([]string{"read_user"}), true},
+		"require_scopes_missing": {token, access.NewRequireScopesAuthorizer([]string{"read_user", "api"}), false},
+		"require_scopes_unknown": {nil, access.NewRequireScopesAuthorizer([]string{"read_user"}), false},
+		"reject_scopes":          {token, access.NewRejectScopesAuthorizer([]string{"api"}), true},
+		"reject_scopes_present":  {token, access.NewRejectScopesAuthorizer([]string{"api", "read_api"}), false},
+		"name_pattern":           {token, access.NewTokenNamePatternAuthorizer(compile("k8s-*")), true},
+		"name_pattern_regexp":    {token, access.NewTokenNamePatternAuthorizer(compile("/k8s-(prod|stage)/")), true},
+		"name_pattern_mismatch":  {token, access.NewTokenNamePatternAuthorizer(compile("ci-*")), false},
+		"name_pattern_unknown":   {nil, access.NewTokenNamePatternAuthorizer(compile("k8s-*")), false},
+		"lifetime":               {token, access.NewMaxTokenLifetimeAuthorizer(90 * 24 * time.Hour), true},
+		"lifetime_exceeded":      {token, access.NewMaxTokenLifetimeAuthorizer(30 * 24 * time.Hour), false},
+		"lifetime_unlimited":     {&unlimited, access.NewMaxTokenLifetimeAuthorizer(90 * 24 * time.Hour), false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			info := authentication.UserInfo{Username: "jdoe"}
+			if tt.have != nil {
+				info = access.TokenUserInfo(info, access.TokenInfo{Details: tt.have})
+			}
+
+			got := tt.subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectImpersonationAuthorizer(t *testing.T) {
+	token := &gitlab.PersonalAccessToken{ID: 42}
+	tests := map[string]struct {
+		have access.TokenInfo
+		want bool
+	}{
+		"regular": {
+			have: access.TokenInfo{Details: token, Impersonation: gitlab.Ptr(false)},
+			want: true,
+		},
+		"impersonation": {
+			have: access.TokenInfo{Details: token, Impersonation: gitlab.Ptr(true)},
+			want: false,
+		},
+		"unknown": {
+			have: access.TokenInfo{Details: token},
+			want: false,
+		},
+		"no_details": {
+			have: access.TokenInfo{Type: "oauth"},
+			want: false,
+		},
+	}
+
+	subject := access.NewRejectImpersonationAuthorizer()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			info := access.TokenUserInfo(authentication.UserInfo{Username: "jdoe"}, tt.have)
+			got := subject.Authorize(context.Background(), userinfo.NewV1UserInfo(info))
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
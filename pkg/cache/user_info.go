@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"time"
 
 	authentication "k8s.io/api/authentication/v1"
@@ -8,17 +11,166 @@ import (
 	ttlcache "github.com/jellydator/ttlcache/v3"
 )
 
-type UserInfoCache = ttlcache.Cache[string, authentication.UserInfo]
+// UserInfoItem is a single cache entry
+type UserInfoItem = ttlcache.Item[string, authentication.UserInfo]
 
-func NewUserInfoCache(ttl time.Duration) *UserInfoCache {
-	result := ttlcache.New[string, authentication.UserInfo](
+// UserInfoCacheOpts defines the behaviour of a [UserInfoCache]
+type UserInfoCacheOpts struct {
+	// Expiration time of cache entries
+	TTL time.Duration
+	// Time window after expiration during which entries
+	// are still available via [UserInfoCache.GetStale].
+	// Stale entries are not retained if not positive.
+	GracePeriod time.Duration
+	// Secret used to derive the cache keys from the tokens.
+	// If no secret is provided, a random one is generated,
+	// which renders the cache keys unique to the running process.
+	Secret []byte
+}
+
+// UserInfoCache stores user information indexed by the tokens
+// used to retrieve them. Tokens are never stored as-is, instead
+// a keyed hash (HMAC-SHA256) of them is used as index, which
+// prevents memory dumps from exposing valid credentials.
+type UserInfoCache struct {
+	cache    *ttlcache.Cache[string, authentication.UserInfo]
+	stale    *ttlcache.Cache[string, authentication.UserInfo]
+	secret   []byte
+	ttl      time.Duration
+	staleTTL time.Duration
+}
+
+// NewUserInfoCache returns a cache configured using the given options.
+func NewUserInfoCache(opts UserInfoCacheOpts) *UserInfoCache {
+	secret := opts.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, sha256.BlockSize)
+		if _, err := rand.Read(secret); err != nil {
+			// the system entropy source is unusable; continuing with
+			// a predictable secret would defeat the purpose of hashing
+			panic(err)
+		}
+	}
+
+	result := &UserInfoCache{
+		cache:  newUserInfoStore(opts.TTL),
+		secret: secret,
+		ttl:    opts.TTL,
+	}
+
+	if opts.GracePeriod > 0 {
+		result.staleTTL = opts.TTL + opts.GracePeriod
+		result.stale = newUserInfoStore(result.staleTTL)
+	}
+
+	return result
+}
+
+func newUserInfoStore(ttl time.Duration) *ttlcache.Cache[string, authentication.UserInfo] {
+	return ttlcache.New[string, authentication.UserInfo](
 		ttlcache.WithDisableTouchOnHit[string, authentication.UserInfo](),
 		ttlcache.WithTTL[string, authentication.UserInfo](ttl),
 	)
+}
 
-	return result
+// Key returns the cache key for the given token
+func (c *UserInfoCache) Key(t string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	_, _ = mac.Write([]byte(t))
+
+	return string(mac.Sum(nil))
+}
+
+// Get returns the cache entry associated with the given token
+// or nil if no such entry exists or it has expired.
+func (c *UserInfoCache) Get(t string) *UserInfoItem {
+	return c.cache.Get(c.Key(t))
+}
+
+// GetStale returns the cache entry associated with the given token
+// even if it has expired, as long as the grace period has not yet passed.
+// Only entries stored using [SetUserInfo] are retained for this purpose.
+func (c *UserInfoCache) GetStale(t string) *UserInfoItem {
+	if c.stale == nil {
+		return nil
+	}
+
+	return c.stale.Get(c.Key(t))
+}
+
+// Delete removes the cache entry associated with the given token,
+// including its stale copy.
+func (c *UserInfoCache) Delete(t string) {
+	k := c.Key(t)
+	c.cache.Delete(k)
+
+	if c.stale != nil {
+		c.stale.Delete(k)
+	}
+}
+
+// Metrics returns the cache statistics
+func (c *UserInfoCache) Metrics() ttlcache.Metrics {
+	return c.cache.Metrics()
+}
+
+// Start launches the cache eviction scheduler.
+// It blocks until [UserInfoCache.Stop] is called.
+func (c *UserInfoCache) Start() {
+	if c.stale != nil {
+		go c.stale.Start()
+	}
+
+	c.cache.Start()
+}
+
+// Stop terminates the cache eviction scheduler.
+func (c *UserInfoCache) Stop() {
+	if c.stale != nil {
+		c.stale.Stop()
+	}
+
+	c.cache.Stop()
 }
 
 func SetUserInfo(c *UserInfoCache, t string, u authentication.UserInfo) {
-	c.Set(t, u, ttlcache.DefaultTTL)
+	k := c.Key(t)
+	c.cache.Set(k, u, ttlcache.DefaultTTL)
+
+	if c.stale != nil {
+		c.stale.Set(k, u, ttlcache.DefaultTTL)
+	}
+}
+
+// SetUserInfoUntil stores the user information, ensuring neither
+// the entry nor its stale copy outlive the given point in time.
+// Nothing is stored if the latter lies in the past.
+func SetUserInfoUntil(c *UserInfoCache, t string, u authentication.UserInfo, deadline time.Time) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return
+	}
+
+	k := c.Key(t)
+	c.cache.Set(k, u, capTTL(c.ttl, remaining))
+
+	if c.stale != nil {
+		c.stale.Set(k, u, capTTL(c.staleTTL, remaining))
+	}
+}
+
+// capTTL limits the given TTL, taking into account that
+// a non-positive TTL denotes entries which never expire.
+func capTTL(ttl, limit time.Duration) time.Duration {
+	if ttl <= 0 {
+		return limit
+	}
+
+	return min(ttl, limit)
+}
+
+// SetUserInfoTTL stores the user information using a TTL
+// which differs from the one the cache has been created with.
+func SetUserInfoTTL(c *UserInfoCache, t string, u authentication.UserInfo, ttl time.Duration) {
+	c.cache.Set(c.Key(t), u, ttl)
 }
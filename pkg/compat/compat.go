@@ -0,0 +1,156 @@
+// Package compat determines the capabilities of a Gitlab instance.
+package compat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+)
+
+const (
+	EditionCommunity  = "ce"
+	EditionEnterprise = "ee"
+)
+
+// Requirement describes a feature which is only
+// available starting with a specific Gitlab version.
+type Requirement struct {
+	// Human readable description of the feature
+	Feature string
+	// Minimum Gitlab version (e.g. 15.2)
+	Version string
+}
+
+// Instance describes a Gitlab installation.
+type Instance struct {
+	Version  string
+	Revision string
+	Edition  string
+}
+
+// Probe queries the version information of the Gitlab instance
+// the given client is configured for. The metadata endpoint is
+// preferred as it reports the edition reliably; Gitlab versions
+// without it are queried using the version endpoint instead.
+// The token is optional; Gitlab rejects anonymous requests though.
+func Probe(ctx context.Context, client *gitlab.Client, token string) (*Instance, error) {
+	meta, _, err := client.Metadata.GetMetadata(requestOptions(ctx, "metadata", token)...)
+	if err == nil {
+		result := &Instance{
+			Version:  meta.Version,
+			Revision: meta.Revision,
+			Edition:  EditionCommunity,
+		}
+		if meta.Enterprise {
+			result.Edition = EditionEnterprise
+		}
+
+		return result, nil
+	}
+
+	if !errors.Is(err, gitlab.ErrNotFound) {
+		return nil, err
+	}
+
+	ver, _, err := client.Version.GetVersion(requestOptions(ctx, "version", token)...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Instance{
+		Version:  ver.Version,
+		Revision: ver.Revision,
+		Edition:  EditionCommunity,
+	}
+	if strings.HasSuffix(ver.Version, "-"+EditionEnterprise) {
+		result.Edition = EditionEnterprise
+	}
+
+	return result, nil
+}
+
+func requestOptions(ctx context.Context, service, token string) []gitlab.RequestOptionFunc {
+	result := []gitlab.RequestOptionFunc{
+		gitlab.WithContext(metrics.NewContextWithService(ctx, service)),
+	}
+
+	if token != "" {
+		result = append(result, gitlab.WithToken(gitlab.PrivateToken, token))
+	}
+
+	return result
+}
+
+// Supports reports whether the instance version is at least
+// the given one. Unparseable versions are considered supported.
+func (i *Instance) Supports(version string) bool {
+	have, ok := parseVersion(i.Version)
+	if !ok {
+		return true
+	}
+
+	want, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+
+	for n := range have {
+		if have[n] != want[n] {
+			return have[n] > want[n]
+		}
+	}
+
+	return true
+}
+
+// Unsupported returns the requirements the instance does not satisfy.
+func (i *Instance) Unsupported(reqs ...Requirement) []Requirement {
+	var result []Requirement
+	for _, req := range reqs {
+		if !i.Supports(req.Version) {
+			result = append(result, req)
+		}
+	}
+
+	return result
+}
+
+// IsUnreachable reports whether the error returned by [Probe]
+// is the result of Gitlab being unavailable, as opposed to
+// Gitlab rejecting the request.
+func IsUnreachable(err error) bool {
+	if err == nil || errors.Is(err, gitlab.ErrNotFound) {
+		return false
+	}
+
+	var resp *gitlab.ErrorResponse
+	if !errors.As(err, &resp) || resp.Response == nil {
+		return true
+	}
+
+	return resp.Response.StatusCode >= http.StatusInternalServerError ||
+		resp.Response.StatusCode == http.StatusTooManyRequests
+}
+
+// parseVersion extracts major, minor, and patch level from
+// version strings such as 17.5.1, 17.5.1-ee, or 17.6.0-pre.
+func parseVersion(v string) (result [3]int, ok bool) {
+	v, _, _ = strings.Cut(v, "-")
+	parts := strings.SplitN(v, ".", 3)
+	for n, p := range parts {
+		i, err := strconv.Atoi(p)
+		if err != nil {
+			return result, false
+		}
+
+		result[n] = i
+	}
+
+	return result, true
+}
@@ -0,0 +1,132 @@
+package compat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/compat"
+)
+
+func TestInstanceSupports(t *testing.T) {
+	tests := map[string]struct {
+		have string
+		want string
+		ok   bool
+	}{
+		"equal":         {have: "15.2.0", want: "15.2", ok: true},
+		"newer_minor":   {have: "15.10.1-ee", want: "15.2", ok: true},
+		"newer_major":   {have: "16.0.0", want: "15.9", ok: true},
+		"older_minor":   {have: "15.1.4", want: "15.2", ok: false},
+		"older_major":   {have: "14.10.0-ee", want: "15.0", ok: false},
+		"pre_release":   {have: "17.6.0-pre", want: "17.6", ok: true},
+		"unparseable":   {have: "unknown", want: "15.2", ok: true},
+		"invalid_match": {have: "15.2.0", want: "latest", ok: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			subject := &compat.Instance{Version: tt.have}
+			if got := subject.Supports(tt.want); got != tt.ok {
+				t.Errorf("Supports(%q) = %v; want %v", tt.want, got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := map[string]struct {
+		metadata    bool
+		status      int
+		wantEdition string
+		wantVersion string
+		wantErr     bool
+		unreachable bool
+	}{
+		"metadata": {
+			metadata:    true,
+			status:      http.StatusOK,
+			wantEdition: compat.EditionEnterprise,
+			wantVersion: "17.5.0-ee",
+		},
+		"version_fallback": {
+			status:      http.StatusOK,
+			wantEdition: compat.EditionCommunity,
+			wantVersion: "15.1.0",
+		},
+		"rejected": {
+			metadata: true,
+			status:   http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		"unavailable": {
+			metadata:    true,
+			status:      http.StatusServiceUnavailable,
+			wantErr:     true,
+			unreachable: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v4/metadata", func(w http.ResponseWriter, r *http.Request) {
+				if !tt.metadata {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"version":"17.5.0-ee","revision":"abc","enterprise":true}`))
+			})
+			mux.HandleFunc("/api/v4/version", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Private-Token") != "token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"version":"15.1.0","revision":"def"}`))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client, err := gitlab.NewClient("", gitlab.WithBaseURL(server.URL), gitlab.WithoutRetries())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := compat.Probe(context.Background(), client, "token")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Probe() = nil; want error")
+				}
+				if u := compat.IsUnreachable(err); u != tt.unreachable {
+					t.Errorf("IsUnreachable() = %v; want %v", u, tt.unreachable)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Probe() = %v; want nil", err)
+			}
+			if got.Version != tt.wantVersion {
+				t.Errorf("Version = %q; want %q", got.Version, tt.wantVersion)
+			}
+			if got.Edition != tt.wantEdition {
+				t.Errorf("Edition = %q; want %q", got.Edition, tt.wantEdition)
+			}
+		})
+	}
+}
+
+func TestIsUnreachable(t *testing.T) {
+	if !compat.IsUnreachable(errors.New("connection refused")) {
+		t.Error("IsUnreachable(network error) = false; want true")
+	}
+	if compat.IsUnreachable(nil) {
+		t.Error("IsUnreachable(nil) = true; want false")
+	}
+}
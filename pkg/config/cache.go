@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/cache"
 )
 
 type Duration struct {
@@ -31,11 +33,21 @@ func (d *Duration) UnmarshalJSON(b []byte) (err error) {
 
 type Cache struct {
 	TTL Duration `json:"ttl"`
+	// Expiration time of rejected credentials.
+	// Rejections are not cached if set to zero.
+	NegativeTTL Duration `json:"negative_ttl"`
+	// Time window after expiration during which identities
+	// are still served if Gitlab is unavailable.
+	GracePeriod Duration `json:"grace_period"`
+	// Secret used to derive cache keys from tokens.
+	// A random value is generated on startup if omitted.
+	Secret string `json:"secret"`
 }
 
 func NewCache() *Cache {
 	result := &Cache{
-		TTL: Duration{2 * time.Minute},
+		TTL:         Duration{2 * time.Minute},
+		NegativeTTL: Duration{30 * time.Second},
 	}
 
 	return result
@@ -44,3 +56,25 @@ func NewCache() *Cache {
 func (c *Cache) ExpirationTime() time.Duration {
 	return c.TTL.Duration
 }
+
+func (c *Cache) NegativeExpirationTime() time.Duration {
+	return c.NegativeTTL.Duration
+}
+
+func (c *Cache) UserInfoCacheOpts() cache.UserInfoCacheOpts {
+	result := cache.UserInfoCacheOpts{
+		TTL:         c.TTL.Duration,
+		GracePeriod: c.GracePeriod.Duration,
+		Secret:      c.KeySecret(),
+	}
+
+	return result
+}
+
+func (c *Cache) KeySecret() []byte {
+	if c.Secret == "" {
+		return nil
+	}
+
+	return []byte(c.Secret)
+}
@@ -21,6 +21,9 @@ type Config struct {
 	Cache   *Cache   `json:"cache"`
 	Web     *Web     `json:"web"`
 
+	// Named Gitlab instances; replaces the gitlab section if provided
+	GitlabInstances []*GitlabInstance `json:"gitlab_instances"`
+
 	file string `json:"-"`
 }
 
@@ -3,14 +3,30 @@ package config
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/net/http/httpproxy"
 
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/compat"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+const (
+	GitlabBackendREST    = "rest"
+	GitlabBackendGraphQL = "graphql"
+	GitlabBackendGitea   = "gitea"
+	GitlabBackendForgejo = "forgejo"
 )
 
 // https://docs.gitlab.com/ee/security/tokens/#token-prefixes
@@ -20,12 +36,51 @@ var GitlabTokenPrefixes = []string{
 	"glsoat-",
 }
 
+// graphQLUnknownAttributes are the account attributes
+// which are not exposed by the Gitlab GraphQL API
+var graphQLUnknownAttributes = []string{
+	access.Attribute2fa,
+	access.AttributeAdmin,
+	access.AttributeAuditor,
+	access.AttributeExternal,
+	access.AttributePrivate,
+	access.AttributeLocked,
+	access.AttributePristine,
+}
+
+// graphQLUnsupportedCriteria are the realm criteria
+// relying on information the GraphQL backend can not provide
+var graphQLUnsupportedCriteria = []string{
+	"require_2fa",
+	"reject_locked",
+	"reject_pristine",
+	"require_admin",
+	"reject_admin",
+	"require_auditor",
+	"reject_auditor",
+	"require_external",
+	"reject_external",
+	"require_private",
+	"reject_private",
+	"require_attributes",
+	"reject_attributes",
+}
+
+// serviceAccountCriteria are the realm criteria relying on
+// information only revealed in service account mode
+var serviceAccountCriteria = []string{
+	"require_attributes",
+	"reject_attributes",
+	"reject_impersonation_tokens",
+}
+
 type GitlabGroupFilter struct {
 	OwnedOnly      bool                    `json:"owned_only"`
 	TopLevelOnly   bool                    `json:"top_level_only"`
 	MinAccessLevel gitlab.AccessLevelValue `json:"min_access_level"`
 	Name           string                  `json:"name"`
 	Limit          uint8                   `json:"limit"`
+	MaxPages       uint                    `json:"max_pages"`
 }
 
 func (f *GitlabGroupFilter) ListOptions() *gitlab.ListGroupsOptions {
@@ -59,64 +114,397 @@ func (f *GitlabGroupFilter) ListOptions() *gitlab.ListGroupsOptions {
 	return result
 }
 
+// PageLimit returns the maximum number of pages to request
+// from Gitlab. Zero means no limit.
+func (f *GitlabGroupFilter) PageLimit() int {
+	return int(f.MaxPages)
+}
+
+type GitlabCircuitBreaker struct {
+	// Consecutive failures before requests are rejected; zero disables the circuit breaker
+	FailureThreshold uint `json:"failure_threshold"`
+	// Time to wait before probing Gitlab again
+	OpenTimeout Duration `json:"open_timeout"`
+	// Number of probe requests once the open timeout has passed
+	HalfOpenRequests uint `json:"half_open_requests"`
+}
+
+func (b *GitlabCircuitBreaker) CircuitBreakerOpts() transport.CircuitBreakerOpts {
+	result := transport.CircuitBreakerOpts{
+		FailureThreshold: int(b.FailureThreshold),
+		OpenTimeout:      b.OpenTimeout.Duration,
+		HalfOpenRequests: int(b.HalfOpenRequests),
+	}
+
+	return result
+}
+
+type GitlabRateLimit struct {
+	// Number of requests to keep in reserve before throttling kicks in
+	Reserve uint `json:"reserve"`
+	// Maximum time to hold back requests before rejecting them
+	MaxWait Duration `json:"max_wait"`
+}
+
+func (l *GitlabRateLimit) RateLimiterOpts() transport.RateLimiterOpts {
+	result := transport.RateLimiterOpts{
+		Reserve: int64(l.Reserve),
+		MaxWait: l.MaxWait.Duration,
+	}
+
+	return result
+}
+
+type GitlabRetry struct {
+	// Number of retries after the initial attempt; zero disables retries
+	MaxRetries uint `json:"max_retries"`
+	// Delay before the first retry, doubled for each subsequent one
+	BaseDelay Duration `json:"base_delay"`
+	// Upper bound of the random delay added to each backoff
+	Jitter Duration `json:"jitter"`
+	// Time budget for all attempts combined
+	Deadline Duration `json:"deadline"`
+}
+
+func (r *GitlabRetry) RetryOpts() transport.RetryOpts {
+	result := transport.RetryOpts{
+		MaxRetries: int(r.MaxRetries),
+		BaseDelay:  r.BaseDelay.Duration,
+		Jitter:     r.Jitter.Duration,
+		Deadline:   r.Deadline.Duration,
+	}
+
+	return result
+}
+
+type GitlabConcurrency struct {
+	// Maximum number of concurrent requests; zero disables the limit
+	MaxRequests uint `json:"max_requests"`
+	// Maximum time to wait for a free slot
+	QueueTimeout Duration `json:"queue_timeout"`
+}
+
+func (c *GitlabConcurrency) ConcurrencyLimiterOpts() transport.ConcurrencyLimiterOpts {
+	result := transport.ConcurrencyLimiterOpts{
+		MaxRequests:  int(c.MaxRequests),
+		QueueTimeout: c.QueueTimeout.Duration,
+	}
+
+	return result
+}
+
+type GitlabHTTP struct {
+	// Maximum time to establish a TCP connection
+	DialTimeout Duration `json:"dial_timeout"`
+	// Maximum time to complete the TLS handshake
+	TLSHandshakeTimeout Duration `json:"tls_handshake_timeout"`
+	// Maximum time to wait for the response headers once the request has been sent
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"`
+	// Time limit for a request including retries; zero means no limit
+	Timeout Duration `json:"timeout"`
+	// Maximum number of idle connections kept open; zero means no limit
+	MaxIdleConns uint `json:"max_idle_conns"`
+	// Maximum number of idle connections kept open per host
+	MaxIdleConnsPerHost uint `json:"max_idle_conns_per_host"`
+	// Time after which idle connections are closed; zero means no limit
+	IdleConnTimeout Duration `json:"idle_conn_timeout"`
+	// Restrict the connection to HTTP/1.1
+	DisableHTTP2 bool `json:"disable_http2"`
+	// Proxy URL; the environment (HTTPS_PROXY, NO_PROXY, ...) is consulted if empty
+	Proxy string `json:"proxy"`
+	// Hosts, domains and networks to connect to directly if a proxy is configured
+	NoProxy []string `json:"no_proxy"`
+	// User-Agent header sent with each request; the client default is used if empty
+	UserAgent string `json:"user_agent"`
+	// Additional headers sent with each request
+	Headers map[string]string `json:"headers"`
+}
+
+// ProxyFunc returns the proxy selection function for the HTTP transport.
+func (h *GitlabHTTP) ProxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if h.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	if _, err := url.Parse(h.Proxy); err != nil {
+		return nil, err
+	}
+
+	cfg := &httpproxy.Config{
+		HTTPProxy:  h.Proxy,
+		HTTPSProxy: h.Proxy,
+		NoProxy:    strings.Join(h.NoProxy, ","),
+	}
+	proxy := cfg.ProxyFunc()
+
+	return func(r *http.Request) (*url.URL, error) {
+		return proxy(r.URL)
+	}, nil
+}
+
+// Configure applies the connection settings to the given transport.
+func (h *GitlabHTTP) Configure(t *http.Transport) (err error) {
+	t.Proxy, err = h.ProxyFunc()
+	if err != nil {
+		return
+	}
+
+	if h.DialTimeout.Duration > 0 {
+		dialer := &net.Dialer{
+			Timeout:   h.DialTimeout.Duration,
+			KeepAlive: 30 * time.Second,
+		}
+		t.DialContext = dialer.DialContext
+	}
+
+	t.TLSHandshakeTimeout = h.TLSHandshakeTimeout.Duration
+	t.ResponseHeaderTimeout = h.ResponseHeaderTimeout.Duration
+	t.MaxIdleConns = int(h.MaxIdleConns)
+	t.MaxIdleConnsPerHost = int(h.MaxIdleConnsPerHost)
+	t.IdleConnTimeout = h.IdleConnTimeout.Duration
+
+	if h.DisableHTTP2 {
+		// a non-nil, empty map prevents the HTTP/2 upgrade during the TLS handshake
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return
+}
+
+// Header returns the static headers to send with each request.
+func (h *GitlabHTTP) Header() http.Header {
+	result := make(http.Header, len(h.Headers)+1)
+	for k, v := range h.Headers {
+		result.Set(k, v)
+	}
+
+	if h.UserAgent != "" {
+		result.Set("User-Agent", h.UserAgent)
+	}
+
+	return result
+}
+
+type GitlabCompatibilityCheck struct {
+	// Query the Gitlab version on startup
+	Enabled bool `json:"enabled"`
+	// Refuse to start if Gitlab is unreachable
+	FailFast bool `json:"fail_fast"`
+	// Time limit for the check
+	Timeout Duration `json:"timeout"`
+}
+
 type Gitlab struct {
 	Server `json:",inline"`
 
-	AttributesAsGroups bool              `json:"attributes_as_groups"`
-	InactivityTimeout  Duration          `json:"inactivity_timeout"`
-	GroupFilter        GitlabGroupFilter `json:"group_filter"`
+	AttributesAsGroups bool                 `json:"attributes_as_groups"`
+	InactivityTimeout  Duration             `json:"inactivity_timeout"`
+	GroupFilter        GitlabGroupFilter    `json:"group_filter"`
+	CircuitBreaker     GitlabCircuitBreaker `json:"circuit_breaker"`
+	RateLimit          GitlabRateLimit      `json:"rate_limit"`
+	Retry              GitlabRetry          `json:"retry"`
+	Concurrency        GitlabConcurrency    `json:"concurrency"`
+	HTTP               GitlabHTTP           `json:"http"`
+
+	// Token used for requests not associated with a review,
+	// such as querying the Gitlab version.
+	ServiceToken       string                   `json:"service_token"`
+	CompatibilityCheck GitlabCompatibilityCheck `json:"compatibility_check"`
+	// Retrieve user details and group memberships using the service token;
+	// the presented token is only used to identify the user.
+	ServiceAccountMode bool `json:"service_account_mode"`
+	// API used to retrieve user information (rest, graphql, gitea, forgejo)
+	Backend string `json:"backend"`
+	// Retrieve the scopes and expiration of the presented token
+	TokenIntrospection bool `json:"token_introspection"`
 
 	TokenPrefixes []string `json:"token_prefixes"`
+	// Authentication scheme (private, oauth, job) of tokens
+	// with the given prefix; private if none matches
+	TokenTypes map[string]string `json:"token_types"`
 }
 
 func NewGitlab() *Gitlab {
 	result := &Gitlab{
 		Server:            *NewServer(),
-		TokenPrefixes:     GitlabTokenPrefixes,
+		TokenPrefixes:     slices.Clone(GitlabTokenPrefixes), // decoding reuses the backing array
 		InactivityTimeout: Duration{time.Hour * 24 * 30 * 6}, // ~6 months
+		Backend:           GitlabBackendREST,
 	}
 	result.Server.Address = "gitlab.com"
 	result.Server.Port = 443
 	result.Server.TLS = &TLS{}
 	result.GroupFilter.Limit = 20                                       // Gitlab Groups API default
+	result.GroupFilter.MaxPages = 10                                    // up to 200 groups with the default limit
 	result.GroupFilter.MinAccessLevel = gitlab.MinimalAccessPermissions // no filter
+	result.CircuitBreaker.FailureThreshold = 5
+	result.CircuitBreaker.OpenTimeout = Duration{30 * time.Second}
+	result.CircuitBreaker.HalfOpenRequests = 1
+	result.RateLimit.Reserve = 10
+	result.RateLimit.MaxWait = Duration{5 * time.Second}
+	result.Retry.MaxRetries = 2
+	result.Retry.BaseDelay = Duration{100 * time.Millisecond}
+	result.Retry.Jitter = Duration{100 * time.Millisecond}
+	result.Retry.Deadline = Duration{3 * time.Second}
+	result.Concurrency.MaxRequests = 32
+	result.Concurrency.QueueTimeout = Duration{2 * time.Second}
+	result.HTTP.DialTimeout = Duration{5 * time.Second}
+	result.HTTP.TLSHandshakeTimeout = Duration{5 * time.Second}
+	result.HTTP.ResponseHeaderTimeout = Duration{5 * time.Second}
+	result.HTTP.Timeout = Duration{10 * time.Second}
+	result.HTTP.MaxIdleConns = 100
+	result.HTTP.MaxIdleConnsPerHost = 32 // matches the concurrency limit
+	result.HTTP.IdleConnTimeout = Duration{90 * time.Second}
+	result.CompatibilityCheck.Enabled = true
+	result.CompatibilityCheck.Timeout = Duration{10 * time.Second}
+	result.TokenIntrospection = true
+	result.TokenTypes = map[string]string{
+		"gloas-": string(identity.TokenTypeOAuth),
+		"glcbt-": string(identity.TokenTypeJob),
+	}
 
 	return result
 }
 
-func (g *Gitlab) HTTPClient() (client *http.Client, err error) {
-	client = http.DefaultClient
-	transport, err := g.HTTPTransport()
-	if err != nil {
-		return
+// ServiceAccountToken returns the token used to retrieve
+// user details or an empty string if the service account mode
+// is disabled.
+func (g *Gitlab) ServiceAccountToken() (string, error) {
+	if !g.ServiceAccountMode {
+		return "", nil
 	}
 
-	if transport == http.DefaultTransport {
-		return
+	if g.ServiceToken == "" {
+		return "", errors.New("service account mode requires a service token")
+	}
+
+	return g.ServiceToken, nil
+}
+
+// GraphQL reports whether user information is to be
+// retrieved using the Gitlab GraphQL API.
+func (g *Gitlab) GraphQL() (bool, error) {
+	switch g.Backend {
+	case "", GitlabBackendREST:
+		return false, nil
+	case GitlabBackendGraphQL:
+		if g.ServiceAccountMode {
+			return false, errors.New("service account mode is not supported by the graphql backend")
+		}
+
+		return true, nil
+	case GitlabBackendGitea, GitlabBackendForgejo:
+		if g.ServiceAccountMode {
+			return false, fmt.Errorf("service account mode is not supported by the %s backend", g.Backend)
+		}
+
+		return false, nil
 	}
 
-	client = &http.Client{
-		Transport: transport,
+	return false, fmt.Errorf("unsupported gitlab backend %q", g.Backend)
+}
+
+// UnsupportedCriteria returns the realm criteria which
+// can not be evaluated using the information provided
+// by the configured backend, mapped to the reason.
+func (g *Gitlab) UnsupportedCriteria() map[string]string {
+	result := make(map[string]string)
+	if g.Backend == GitlabBackendGraphQL {
+		for _, c := range graphQLUnsupportedCriteria {
+			result[c] = "is not supported by the graphql backend"
+		}
+	}
+
+	if !g.ServiceAccountMode {
+		reason := "requires the service account mode"
+		if g.Backend == GitlabBackendGraphQL || g.Gitea() {
+			reason = fmt.Sprintf("is not supported by the %s backend", g.Backend)
+		}
+
+		for _, c := range serviceAccountCriteria {
+			if _, ok := result[c]; !ok {
+				result[c] = reason
+			}
+		}
+	}
+
+	return result
+}
+
+// Gitea reports whether the server is a Gitea
+// (or Forgejo) instance instead of Gitlab.
+func (g *Gitlab) Gitea() bool {
+	return g.Backend == GitlabBackendGitea || g.Backend == GitlabBackendForgejo
+}
+
+// Requirements returns the Gitlab features required
+// by the current configuration.
+func (g *Gitlab) Requirements() []compat.Requirement {
+	var result []compat.Requirement
+
+	if g.GroupFilter.TopLevelOnly {
+		result = append(result, compat.Requirement{
+			Feature: "group filter top_level_only",
+			Version: "15.0",
+		})
+	}
+
+	if g.GroupFilter.MinAccessLevel > gitlab.MinimalAccessPermissions {
+		result = append(result, compat.Requirement{
+			Feature: "group filter min_access_level",
+			Version: "11.2",
+		})
+	}
+
+	if g.TokenIntrospection {
+		result = append(result, compat.Requirement{
+			Feature: "token introspection",
+			Version: "15.5",
+		})
+	}
+
+	return result
+}
+
+// HTTPClient returns a client for communicating with Gitlab.
+// The transport is decorated with the given middlewares,
+// with the first one being the outermost.
+func (g *Gitlab) HTTPClient(middlewares ...transport.Middleware) (*http.Client, error) {
+	rt, err := g.HTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	if header := g.HTTP.Header(); len(header) > 0 {
+		middlewares = append(middlewares, transport.StaticHeaders(header))
 	}
-	return
 
+	client := &http.Client{
+		Transport: transport.Chain(rt, middlewares...),
+		Timeout:   g.HTTP.Timeout.Duration,
+	}
+	return client, nil
 }
 
-func (g *Gitlab) HTTPTransport() (transport http.RoundTripper, err error) {
-	transport = http.DefaultTransport
+// HTTPTransport returns a transport derived from [http.DefaultTransport]
+// using the configured connection and TLS settings.
+func (g *Gitlab) HTTPTransport() (*http.Transport, error) {
 	mtls, err := g.MTLS()
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	if mtls == nil {
-		return
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+	if err := g.HTTP.Configure(rt); err != nil {
+		return nil, err
 	}
 
-	transport = &http.Transport{
-		TLSClientConfig: mtls,
+	if mtls != nil {
+		rt.TLSClientConfig = mtls
 	}
-	return
+
+	return rt, nil
 }
 
 func (g *Gitlab) MTLS() (cfg *tls.Config, err error) {
@@ -187,9 +575,29 @@ func (g *Gitlab) UserInfoOptions() *access.UserInfoOptions {
 		DormantTimeout:     g.InactivityTimeout.Duration,
 	}
 
+	if g.Backend == GitlabBackendGraphQL {
+		result.UnknownAttributes = graphQLUnknownAttributes
+	}
+
 	return result
 }
 
+// TokenClassifier returns the mapping of token prefixes
+// onto their authentication scheme.
+func (g *Gitlab) TokenClassifier() (identity.TokenTypes, error) {
+	result := make(identity.TokenTypes, len(g.TokenTypes))
+	for prefix, v := range g.TokenTypes {
+		typ := identity.TokenType(v)
+		if !typ.Valid() {
+			return nil, fmt.Errorf("invalid type %q for tokens with prefix %q", v, prefix)
+		}
+
+		result[prefix] = typ
+	}
+
+	return result, nil
+}
+
 func (g *Gitlab) TokenValidator() func(string) bool {
 	result := func(v string) bool {
 		for _, p := range g.TokenPrefixes {
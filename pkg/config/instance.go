@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+// GitlabInstance is a named Gitlab instance. Identities originating
+// from it are namespaced to avoid collisions with other instances.
+type GitlabInstance struct {
+	Gitlab `json:",inline"`
+
+	// Identifier of the instance used in logs and metrics
+	Name string `json:"name"`
+	// Prefix of usernames, UIDs and groups; defaults to the name
+	Namespace string `json:"namespace"`
+	// Realms whose lookups are exclusively routed to this instance
+	Realms []string `json:"realms"`
+	// Whether tokens not recognized by preceding instances
+	// with overlapping token prefixes are passed on to this one
+	Fallback bool `json:"fallback"`
+}
+
+// UnmarshalJSON populates the instance with the
+// defaults of [NewGitlab] before decoding the data.
+func (i *GitlabInstance) UnmarshalJSON(b []byte) error {
+	type plain GitlabInstance
+	result := plain{
+		Gitlab: *NewGitlab(),
+	}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+
+	*i = GitlabInstance(result)
+	return nil
+}
+
+// NamespacePrefix returns the string prepended to identities
+// from this instance or an empty string for unnamed instances.
+func (i *GitlabInstance) NamespacePrefix() string {
+	if i.Namespace != "" {
+		return i.Namespace + ":"
+	}
+
+	if i.Name != "" {
+		return i.Name + ":"
+	}
+
+	return ""
+}
+
+// Instances returns the configured Gitlab instances. If none are
+// configured, the result consists of an unnamed instance based on
+// the [Config.Gitlab] settings, whose identities are used as-is.
+func (c *Config) Instances() ([]*GitlabInstance, error) {
+	if len(c.GitlabInstances) == 0 {
+		return []*GitlabInstance{{Gitlab: *c.Gitlab}}, nil
+	}
+
+	names := make(map[string]bool, len(c.GitlabInstances))
+	for _, i := range c.GitlabInstances {
+		if i.Name == "" {
+			return nil, errors.New("gitlab instances require a name")
+		}
+
+		if names[i.Name] {
+			return nil, fmt.Errorf("duplicate gitlab instance %q", i.Name)
+		}
+		names[i.Name] = true
+
+		for _, r := range i.Realms {
+			if _, ok := c.Realms[r]; !ok {
+				return nil, fmt.Errorf("gitlab instance %q is bound to unknown realm %q", i.Name, r)
+			}
+		}
+	}
+
+	return c.GitlabInstances, nil
+}
+
+// TokenValidator returns a function accepting tokens
+// which are accepted by any of the configured instances.
+func (c *Config) TokenValidator() (func(string) bool, error) {
+	instances, err := c.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make([]func(string) bool, len(instances))
+	for i, inst := range instances {
+		validators[i] = inst.TokenValidator()
+	}
+
+	result := func(v string) bool {
+		for _, validate := range validators {
+			if validate(v) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return result, nil
+}
+
+// TokenClassifier returns a function determining the type of a token
+// based on the first instance accepting it.
+func (c *Config) TokenClassifier() (func(string) identity.TokenType, error) {
+	instances, err := c.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make([]func(string) bool, len(instances))
+	classifiers := make([]identity.TokenTypes, len(instances))
+	for i, inst := range instances {
+		validators[i] = inst.TokenValidator()
+		classifiers[i], err = inst.TokenClassifier()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := func(v string) identity.TokenType {
+		for i, validate := range validators {
+			if validate(v) {
+				return classifiers[i].Of(v)
+			}
+		}
+
+		return identity.TokenTypePrivate
+	}
+
+	return result, nil
+}
+
+// UserAccessControlList returns the authorizers of each realm
+// (see [Realms.UserAccessControlList]). Realms relying on criteria
+// which can not be evaluated by the instances consulted on their
+// behalf are reported as error.
+func (c *Config) UserAccessControlList() (map[string]userauthz.Authorizer, error) {
+	instances, err := c.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	for realm, acls := range c.Realms {
+		for _, inst := range instances {
+			if !inst.consulted(realm, instances) {
+				continue
+			}
+
+			unsupported := inst.UnsupportedCriteria()
+			for i, rules := range acls {
+				for _, criterion := range rules.Criteria() {
+					if reason, ok := unsupported[criterion]; ok {
+						return nil, fmt.Errorf("realm %q: rule #%d: %s %s%s",
+							realm, i+1, criterion, reason, inst.label())
+					}
+				}
+			}
+		}
+	}
+
+	return c.Realms.UserAccessControlList()
+}
+
+// consulted reports whether the instance takes part in lookups on
+// behalf of the given realm, i.e. the realm is either bound to it
+// or not bound to any of the given instances.
+func (i *GitlabInstance) consulted(realm string, instances []*GitlabInstance) bool {
+	if slices.Contains(i.Realms, realm) {
+		return true
+	}
+
+	for _, inst := range instances {
+		if slices.Contains(inst.Realms, realm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// label returns a reference to the instance suitable
+// for error messages or an empty string for unnamed instances.
+func (i *GitlabInstance) label() string {
+	if i.Name == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" of gitlab instance %q", i.Name)
+}
@@ -1,11 +1,26 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
 	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
 
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
 )
 
+// RealmGroupQuorum requires membership of a minimum number of groups.
+type RealmGroupQuorum struct {
+	// Number of groups a user has to be a member of
+	Count int `json:"count"`
+	// Groups to consider
+	Groups []string `json:"groups"`
+}
+
 type RealmAccessRules struct {
 	// Reject users without 2FA set up
 	Require2FA bool `json:"require_2fa"`
@@ -17,17 +32,97 @@ type RealmAccessRules struct {
 	RejectPristine bool `json:"reject_pristine"`
 	// Reject users which have not had any activity for some time
 	RejectDormant bool `json:"reject_dormant"`
+	// Only allow users whose account is in the given state (e.g. active)
+	RequireState string `json:"require_state"`
+	// Reject users whose account is in any of the given states
+	RejectStates []string `json:"reject_states"`
+	// Only allow administrators
+	RequireAdmin bool `json:"require_admin"`
+	// Reject administrators
+	RejectAdmin bool `json:"reject_admin"`
+	// Only allow auditors
+	RequireAuditor bool `json:"require_auditor"`
+	// Reject auditors
+	RejectAuditor bool `json:"reject_auditor"`
+	// Only allow users marked as external
+	RequireExternal bool `json:"require_external"`
+	// Reject users marked as external
+	RejectExternal bool `json:"reject_external"`
+	// Only allow users with a private profile
+	RequirePrivate bool `json:"require_private"`
+	// Reject users with a private profile
+	RejectPrivate bool `json:"reject_private"`
 	// Only allow users with the given usernames
 	RequireUsers []string `json:"require_users"`
 	// Reject users based on their username
 	RejectUsers []string `json:"reject_users"`
 	// Require membership of all of these groups
 	RequireGroups []string `json:"require_groups"`
+	// Require membership of at least one of these groups
+	RequireAnyGroups []string `json:"require_any_groups"`
+	// Require membership of a minimum number of groups
+	RequireMinGroups *RealmGroupQuorum `json:"require_min_groups"`
 	// Reject members of any of the given groups
 	RejectGroups []string `json:"reject_groups"`
+	// Only allow users whose name matches any of the given patterns
+	RequireUserPatterns []string `json:"require_user_patterns"`
+	// Reject users whose name matches any of the given patterns
+	RejectUserPatterns []string `json:"reject_user_patterns"`
+	// Require membership of a group matching each of these patterns
+	RequireGroupPatterns []string `json:"require_group_patterns"`
+	// Reject members of any group matching one of the given patterns
+	RejectGroupPatterns []string `json:"reject_group_patterns"`
+	// Require each custom attribute to have one of the given values (or patterns)
+	RequireAttributes map[string][]string `json:"require_attributes"`
+	// Reject users with any custom attribute having one of the given values (or patterns)
+	RejectAttributes map[string][]string `json:"reject_attributes"`
+	// Require the token to have all of these scopes
+	RequireScopes []string `json:"require_scopes"`
+	// Reject tokens with any of the given scopes
+	RejectScopes []string `json:"reject_scopes"`
+	// Only allow tokens whose name matches the given pattern
+	TokenNamePattern string `json:"token_name_pattern"`
+	// Reject tokens which are valid for longer than this or do not expire
+	MaxTokenLifetime Duration `json:"max_token_lifetime"`
+	// Reject tokens issued by an administrator to impersonate the user
+	RejectImpersonationTokens bool `json:"reject_impersonation_tokens"`
+	// Only allow CI pipelines of the given projects
+	RequireProjects []string `json:"require_projects"`
+	// Only allow CI pipelines running for the given branches or tags
+	RequireRefs []string `json:"require_refs"`
+	// Only allow CI pipelines running for protected branches or tags
+	RequireProtectedRef bool `json:"require_protected_ref"`
+}
+
+// UnmarshalJSON decodes the rules while rejecting unknown criteria,
+// as ignoring them would grant access more liberally than intended.
+func (r *RealmAccessRules) UnmarshalJSON(b []byte) error {
+	type plain RealmAccessRules
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode((*plain)(r))
+}
+
+// Criteria returns the names of the criteria set by the rules.
+func (r *RealmAccessRules) Criteria() []string {
+	var result []string
+	v := reflect.ValueOf(r).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		value := v.Field(i)
+		if value.IsZero() || ((value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		result = append(result, name)
+	}
+
+	return result
 }
 
-func (r *RealmAccessRules) UserRules() userauthz.Authorizer {
+func (r *RealmAccessRules) UserRules() (userauthz.Authorizer, error) {
 	result := []userauthz.Authorizer{}
 
 	if r.Require2FA {
@@ -50,6 +145,46 @@ func (r *RealmAccessRules) UserRules() userauthz.Authorizer {
 		result = append(result, access.NewRejectDormantAuthorizer())
 	}
 
+	if r.RequireState != "" {
+		result = append(result, access.NewRequireStateAuthorizer(r.RequireState))
+	}
+
+	if len(r.RejectStates) > 0 {
+		result = append(result, access.NewRejectStatesAuthorizer(r.RejectStates))
+	}
+
+	if r.RequireAdmin {
+		result = append(result, access.NewRequireAdminAuthorizer())
+	}
+
+	if r.RejectAdmin {
+		result = append(result, access.NewRejectAdminAuthorizer())
+	}
+
+	if r.RequireAuditor {
+		result = append(result, access.NewRequireAuditorAuthorizer())
+	}
+
+	if r.RejectAuditor {
+		result = append(result, access.NewRejectAuditorAuthorizer())
+	}
+
+	if r.RequireExternal {
+		result = append(result, access.NewRequireExternalAuthorizer())
+	}
+
+	if r.RejectExternal {
+		result = append(result, access.NewRejectExternalAuthorizer())
+	}
+
+	if r.RequirePrivate {
+		result = append(result, access.NewRequirePrivateAuthorizer())
+	}
+
+	if r.RejectPrivate {
+		result = append(result, access.NewRejectPrivateAuthorizer())
+	}
+
 	if len(r.RequireUsers) > 0 {
 		result = append(result, access.NewRequireUsersAuthorizer(r.RequireUsers))
 	}
@@ -58,6 +193,18 @@ func (r *RealmAccessRules) UserRules() userauthz.Authorizer {
 		result = append(result, access.NewRequireGroupsAuthorizer(r.RequireGroups))
 	}
 
+	if len(r.RequireAnyGroups) > 0 {
+		result = append(result, access.NewRequireAnyGroupsAuthorizer(r.RequireAnyGroups))
+	}
+
+	if q := r.RequireMinGroups; q != nil {
+		if q.Count < 1 || q.Count > len(q.Groups) {
+			return nil, fmt.Errorf("require_min_groups count %d is not within 1 and %d", q.Count, len(q.Groups))
+		}
+
+		result = append(result, access.NewRequireMinGroupsAuthorizer(q.Count, q.Groups))
+	}
+
 	if len(r.RejectUsers) > 0 {
 		result = append(result, access.NewRejectUsersAuthorizer(r.RejectUsers))
 	}
@@ -66,21 +213,136 @@ func (r *RealmAccessRules) UserRules() userauthz.Authorizer {
 		result = append(result, access.NewRejectGroupsAuthorizer(r.RejectGroups))
 	}
 
-	return userauthz.RequireAll(result)
+	if len(r.RequireUserPatterns) > 0 {
+		patterns, err := access.CompilePatterns(r.RequireUserPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRequireUserPatternsAuthorizer(patterns))
+	}
+
+	if len(r.RejectUserPatterns) > 0 {
+		patterns, err := access.CompilePatterns(r.RejectUserPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRejectUserPatternsAuthorizer(patterns))
+	}
+
+	if len(r.RequireGroupPatterns) > 0 {
+		patterns, err := access.CompilePatterns(r.RequireGroupPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRequireGroupPatternsAuthorizer(patterns))
+	}
+
+	if len(r.RejectGroupPatterns) > 0 {
+		patterns, err := access.CompilePatterns(r.RejectGroupPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRejectGroupPatternsAuthorizer(patterns))
+	}
+
+	if len(r.RequireAttributes) > 0 {
+		attributes, err := compileAttributePatterns(r.RequireAttributes)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRequireAttributesAuthorizer(attributes))
+	}
+
+	if len(r.RejectAttributes) > 0 {
+		attributes, err := compileAttributePatterns(r.RejectAttributes)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, access.NewRejectAttributesAuthorizer(attributes))
+	}
+
+	if len(r.RequireScopes) > 0 {
+		result = append(result, access.NewRequireScopesAuthorizer(r.RequireScopes))
+	}
+
+	if len(r.RejectScopes) > 0 {
+		result = append(result, access.NewRejectScopesAuthorizer(r.RejectScopes))
+	}
+
+	if r.TokenNamePattern != "" {
+		pattern, err := access.CompilePattern(r.TokenNamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token name pattern %q: %w", r.TokenNamePattern, err)
+		}
+
+		result = append(result, access.NewTokenNamePatternAuthorizer(pattern))
+	}
+
+	if r.MaxTokenLifetime.Duration > 0 {
+		result = append(result, access.NewMaxTokenLifetimeAuthorizer(r.MaxTokenLifetime.Duration))
+	}
+
+	if r.RejectImpersonationTokens {
+		result = append(result, access.NewRejectImpersonationAuthorizer())
+	}
+
+	if len(r.RequireProjects) > 0 {
+		result = append(result, access.NewRequireProjectsAuthorizer(r.RequireProjects))
+	}
+
+	if len(r.RequireRefs) > 0 {
+		result = append(result, access.NewRequireRefsAuthorizer(r.RequireRefs))
+	}
+
+	if r.RequireProtectedRef {
+		result = append(result, access.NewRequireProtectedRefAuthorizer())
+	}
+
+	if len(r.RequireProjects) == 0 && len(r.RequireRefs) == 0 && !r.RequireProtectedRef {
+		// CI pipelines have to be admitted explicitly
+		result = append(result, access.NewRejectPipelineAuthorizer())
+	}
+
+	return userauthz.RequireAll(result), nil
+}
+
+func compileAttributePatterns(attributes map[string][]string) (map[string][]*regexp.Regexp, error) {
+	result := make(map[string][]*regexp.Regexp, len(attributes))
+	for key, values := range attributes {
+		patterns, err := access.CompilePatterns(values)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", key, err)
+		}
+
+		result[key] = patterns
+	}
+
+	return result, nil
 }
 
 type RealmAccessList []*RealmAccessRules
 
-func (r RealmAccessList) UserRules() userauthz.Authorizer {
+func (r RealmAccessList) UserRules() (userauthz.Authorizer, error) {
 	result := make([]userauthz.Authorizer, len(r))
 	for i, u := range r {
-		result[i] = u.UserRules()
+		rules, err := u.UserRules()
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+
+		result[i] = rules
 	}
 
 	return userauthz.RejectNoOpinion(
 		userauthz.RequireAny(result),
 		userauthz.Decision("No explicit permission"),
-	)
+	), nil
 }
 
 type Realms map[string]RealmAccessList
@@ -89,19 +351,26 @@ func NewRealms() map[string]RealmAccessList {
 	return map[string]RealmAccessList{}
 }
 
-func (r Realms) UserAccessControlList() map[string]userauthz.Authorizer {
+// UserAccessControlList returns the authorizers of each realm.
+// Patterns are compiled once; invalid ones are reported as error.
+func (r Realms) UserAccessControlList() (map[string]userauthz.Authorizer, error) {
 	if len(r) == 0 {
-		// allow anyone into the default realm
-		// if nothing has been configured
+		// allow anyone but CI pipelines into the
+		// default realm if nothing has been configured
 		return map[string]userauthz.Authorizer{
-			"": userauthz.AlwaysAllowAuthorizer,
-		}
+			"": access.NewRejectPipelineAuthorizer(),
+		}, nil
 	}
 
 	result := make(map[string]userauthz.Authorizer, len(r))
 	for realm, acls := range r {
-		result[realm] = acls.UserRules()
+		rules, err := acls.UserRules()
+		if err != nil {
+			return nil, fmt.Errorf("realm %q: %w", realm, err)
+		}
+
+		result[realm] = rules
 	}
 
-	return result
+	return result, nil
 }
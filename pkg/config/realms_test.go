@@ -0,0 +1,255 @@
+package config_test
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	userauthz "github.com/UiP9AV6Y/go-k8s-user-authz"
+	"github.com/UiP9AV6Y/go-k8s-user-authz/userinfo"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/config"
+)
+
+func TestRealmAccessRulesUnmarshal(t *testing.T) {
+	tests := map[string]struct {
+		have    string
+		wantErr bool
+	}{
+		"known": {
+			have: `
+common: &common
+  reject_locked: true
+realms:
+  admins:
+    - require_admin: true
+      reject_external: true
+      <<: *common
+`,
+		},
+		"unknown": {
+			have: `
+realms:
+  admins:
+    - require_administrator: true
+`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got struct {
+				Realms config.Realms `json:"realms"`
+			}
+
+			err := yaml.Unmarshal([]byte(tt.have), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() = %v; want error: %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			rules := got.Realms["admins"][0]
+			if !rules.RequireAdmin || !rules.RejectExternal || !rules.RejectLocked {
+				t.Errorf("rules = %+v; want require_admin, reject_external, reject_locked", rules)
+			}
+		})
+	}
+}
+
+func TestRealmsUserAccessControlList(t *testing.T) {
+	subject := config.Realms{
+		"valid": {{RequireGroupPatterns: []string{"platform:**"}}},
+	}
+	if _, err := subject.UserAccessControlList(); err != nil {
+		t.Fatalf("UserAccessControlList() = %v; want nil", err)
+	}
+
+	invalid := map[string]*config.RealmAccessRules{
+		"pattern": {RejectUserPatterns: []string{"/svc-(/"}},
+		"quorum":  {RequireMinGroups: &config.RealmGroupQuorum{Count: 3, Groups: []string{"a", "b"}}},
+		"token":   {TokenNamePattern: "/k8s-(/"},
+	}
+	for name, rules := range invalid {
+		subject := config.Realms{"invalid": {rules}}
+		if _, err := subject.UserAccessControlList(); err == nil {
+			t.Errorf("UserAccessControlList(%s) = nil; want error", name)
+		}
+	}
+}
+
+func TestConfigUserAccessControlList(t *testing.T) {
+	tests := map[string]struct {
+		have    string
+		wantErr bool
+	}{
+		"rest": {
+			have: `
+realms:
+  admins:
+    - require_admin: true
+`,
+		},
+		"graphql_supported": {
+			have: `
+gitlab:
+  backend: graphql
+realms:
+  ops:
+    - reject_bots: true
+      require_groups: [ ops ]
+`,
+		},
+		"graphql_unsupported": {
+			have: `
+gitlab:
+  backend: graphql
+realms:
+  ops:
+    - reject_bots: true
+    - reject_pristine: true
+`,
+			wantErr: true,
+		},
+		"instance_bound": {
+			have: `
+gitlab_instances:
+  - name: corp
+    realms: [ admins ]
+  - name: saas
+    backend: graphql
+realms:
+  admins:
+    - require_admin: true
+  ops:
+    - require_groups: [ ops ]
+`,
+		},
+		"instance_unbound": {
+			have: `
+gitlab_instances:
+  - name: corp
+    realms: [ admins ]
+  - name: saas
+    backend: graphql
+realms:
+  admins:
+    - require_admin: true
+  ops:
+    - require_2fa: true
+`,
+			wantErr: true,
+		},
+		"attributes": {
+			have: `
+gitlab:
+  service_account_mode: true
+  service_token: glpat-service
+realms:
+  ops:
+    - reject_attributes:
+        employment: [ contractor ]
+`,
+		},
+		"attributes_without_service_account": {
+			have: `
+realms:
+  ops:
+    - reject_attributes:
+        employment: [ contractor ]
+`,
+			wantErr: true,
+		},
+		"impersonation": {
+			have: `
+gitlab:
+  service_account_mode: true
+  service_token: glpat-service
+realms:
+  ops:
+    - reject_impersonation_tokens: true
+`,
+		},
+		"impersonation_without_service_account": {
+			have: `
+realms:
+  ops:
+    - reject_impersonation_tokens: true
+`,
+			wantErr: true,
+		},
+		"attributes_gitea": {
+			have: `
+gitlab:
+  backend: gitea
+realms:
+  ops:
+    - require_attributes:
+        team: [ ops ]
+`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			subject := config.New()
+			if err := yaml.Unmarshal([]byte(tt.have), subject); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := subject.UserAccessControlList()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserAccessControlList() = %v; want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRealmsPipelines(t *testing.T) {
+	pipeline := userinfo.NewV1UserInfo(access.PipelineUserInfo(access.PipelineInfo{
+		Project: "platform/deploy",
+		Ref:     "main",
+	}))
+	tests := map[string]struct {
+		have config.Realms
+		want bool
+	}{
+		"default": {
+			have: config.Realms{},
+		},
+		"unrestricted": {
+			have: config.Realms{"": {{}}},
+		},
+		"human_criteria": {
+			have: config.Realms{"": {{RejectGroups: []string{"contractors"}}}},
+		},
+		"pipeline_criteria": {
+			have: config.Realms{"": {{RequireRefs: []string{"main"}}}},
+			want: true,
+		},
+		"any_rule": {
+			have: config.Realms{"": {{}, {RequireProjects: []string{"platform/deploy"}}}},
+			want: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			acls, err := tt.have.UserAccessControlList()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := acls[""].Authorize(context.Background(), pipeline)
+			if (got == userauthz.DecisionAllow) != tt.want {
+				t.Errorf("Authorize() = %q; want allowed: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 var rootPath, _ = url.Parse("/")
@@ -23,10 +24,17 @@ type Server struct {
 	Port    uint   `json:"port"`
 
 	Path string `json:"path"`
+
+	// Time limit of identity lookups shared among concurrent
+	// review requests; must not exceed the webhook timeout
+	// of kube-apiserver. The time is not limited if set to zero.
+	LookupTimeout Duration `json:"lookup_timeout"`
 }
 
 func NewServer() *Server {
-	result := &Server{}
+	result := &Server{
+		LookupTimeout: Duration{10 * time.Second},
+	}
 
 	return result
 }
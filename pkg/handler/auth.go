@@ -10,7 +10,7 @@ import (
 	"net/http"
 	"time"
 
-	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/sync/singleflight"
 
 	authentication "k8s.io/api/authentication/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,8 +20,8 @@ import (
 
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/access"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/cache"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
 	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
-	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
 )
 
 var (
@@ -36,37 +36,46 @@ const (
 const unauthorizedUsername = "n/a"
 
 type AuthHandler struct {
-	client *gitlab.Client
+	source identity.Source
+	flight *singleflight.Group
 	logger *slog.Logger
 	stats  *metrics.Metrics
 
 	preflight func(string) bool
+	tokenType func(string) identity.TokenType
 
-	listGroups *gitlab.ListGroupsOptions
-	userInfo   *access.UserInfoOptions
+	userInfo        *access.UserInfoOptions
+	backendUserInfo map[string]*access.UserInfoOptions
 
-	userAuth  map[string]userauthz.Authorizer
-	userCache *cache.UserInfoCache
+	userAuth      map[string]userauthz.Authorizer
+	userCache     *cache.UserInfoCache
+	negativeTTL   time.Duration
+	lookupTimeout time.Duration
 }
 
-func NewAuthHandler(client *gitlab.Client, logger *slog.Logger, opts ...func(*AuthHandler)) (result *AuthHandler, err error) {
-	listGroups := new(gitlab.ListGroupsOptions)
+func NewAuthHandler(source identity.Source, logger *slog.Logger, opts ...func(*AuthHandler)) (result *AuthHandler, err error) {
 	userInfo := new(access.UserInfoOptions)
 	userAuth := map[string]userauthz.Authorizer{
-		"": userauthz.AlwaysAllowAuthorizer,
+		"": access.NewRejectPipelineAuthorizer(),
 	}
-	userCache := cache.NewUserInfoCache(1 * time.Hour)
+	userCache := cache.NewUserInfoCache(cache.UserInfoCacheOpts{
+		TTL: 1 * time.Hour,
+	})
 	preflight := func(_ string) bool {
 		return true
 	}
 	result = &AuthHandler{
-		client:     client,
-		logger:     logger,
-		preflight:  preflight,
-		listGroups: listGroups,
-		userInfo:   userInfo,
-		userAuth:   userAuth,
-		userCache:  userCache,
+		source:    source,
+		flight:    new(singleflight.Group),
+		logger:    logger,
+		preflight: preflight,
+		tokenType: identity.TokenTypes(nil).Of,
+		userInfo:  userInfo,
+		userAuth:  userAuth,
+		userCache: userCache,
+
+		negativeTTL:   30 * time.Second,
+		lookupTimeout: 10 * time.Second,
 	}
 
 	for _, o := range opts {
@@ -80,15 +89,18 @@ func NewAuthHandler(client *gitlab.Client, logger *slog.Logger, opts ...func(*Au
 	return
 }
 
-func WithAuthGroupFilter(v *gitlab.ListGroupsOptions) func(*AuthHandler) {
+func WithAuthUserTransform(v *access.UserInfoOptions) func(*AuthHandler) {
 	return func(h *AuthHandler) {
-		h.listGroups = v
+		h.userInfo = v
 	}
 }
 
-func WithAuthUserTransform(v *access.UserInfoOptions) func(*AuthHandler) {
+// WithAuthBackendUserTransform defines the transformation options
+// for identities originating from the named backends. Identities
+// from other backends use the options of [WithAuthUserTransform].
+func WithAuthBackendUserTransform(v map[string]*access.UserInfoOptions) func(*AuthHandler) {
 	return func(h *AuthHandler) {
-		h.userInfo = v
+		h.backendUserInfo = v
 	}
 }
 
@@ -104,6 +116,25 @@ func WithAuthUserCache(v *cache.UserInfoCache) func(*AuthHandler) {
 	}
 }
 
+// WithAuthNegativeCacheTTL defines the expiration time of
+// credentials rejected by Gitlab. Rejections are not cached
+// if the value is not positive.
+func WithAuthNegativeCacheTTL(v time.Duration) func(*AuthHandler) {
+	return func(h *AuthHandler) {
+		h.negativeTTL = v
+	}
+}
+
+// WithAuthLookupTimeout defines the time limit of identity lookups.
+// Lookups are shared among concurrent reviews of the same token and
+// therefore not bound to the request which initiated them.
+// The time is not limited if the value is not positive.
+func WithAuthLookupTimeout(v time.Duration) func(*AuthHandler) {
+	return func(h *AuthHandler) {
+		h.lookupTimeout = v
+	}
+}
+
 func WithAuthMetrics(v *metrics.Metrics) func(*AuthHandler) {
 	return func(h *AuthHandler) {
 		h.stats = v
@@ -116,44 +147,85 @@ func WithAuthTokenValidator(v func(string) bool) func(*AuthHandler) {
 	}
 }
 
+// WithAuthTokenTypes defines the function determining the type
+// of a token, which is used to label the authentication metrics.
+func WithAuthTokenTypes(v func(string) identity.TokenType) func(*AuthHandler) {
+	return func(h *AuthHandler) {
+		h.tokenType = v
+	}
+}
+
 func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	s := r.PathValue("realm")
 	t, m, err := parseReviewToken(r.Body)
+	y := string(h.tokenType(t))
 	if err != nil || !h.preflight(t) {
 		if err == nil {
 			err = ErrMalformedToken
 		}
 		h.logger.Info("Invalid authentication request received", "err", err)
-		h.stats.AuthMalformed(s)
+		h.stats.AuthMalformed(s, y)
 		h.rejectReview(w, m, "malformed review request", http.StatusBadRequest)
 		return
 	}
 
 	var i authentication.UserInfo
-	cached := h.userCache.Get(t)
+	k := h.cacheKey(s, t)
+	cached := h.userCache.Get(k)
 	if cached == nil {
-		u, g, err := h.authenticate(r.Context(), t)
-		if err != nil {
-			i.Username = u.Username      // for logging purposes later on
-			i.UID = unauthorizedUsername // mark as invalid
+		id, err := h.authenticateOnce(r.Context(), s, k, t)
+		if err != nil && !IsCredentialRejection(err) {
+			var stale *cache.UserInfoItem
+			if IsOutage(err) {
+				stale = h.userCache.GetStale(k)
+			}
+			if stale == nil {
+				h.logger.Warn("Gitlab is unable to authenticate", "user", id.Username(unauthorizedUsername), "err", err)
+				h.stats.AuthUnavailable(s, y)
+				h.rejectReview(w, m, "identity provider unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			i = access.StaleUserInfo(stale.Value())
+			h.logger.Warn("Using stale authentication", "user", i.Username, "expires", stale.ExpiresAt(), "err", err)
+			h.stats.AuthStale(s)
+		} else if err != nil {
+			i.Username = id.Username(unauthorizedUsername) // for logging purposes later on
+			i.UID = unauthorizedUsername                   // mark as invalid
 			h.logger.Info("Authentication failed", "user", i.Username, "err", err)
-			cache.SetUserInfo(h.userCache, t, i)
-			h.stats.AuthNotFound(s)
+			// revoked credentials must not be revived during an outage
+			h.userCache.Delete(k)
+			if h.negativeTTL > 0 {
+				cache.SetUserInfoTTL(h.userCache, k, i, h.negativeTTL)
+			}
+			h.stats.AuthNotFound(s, y)
 			h.rejectReview(w, m, "unable to review request", http.StatusUnauthorized)
 			return
+		} else {
+			i = h.userInfoOf(id)
+			i = access.TokenUserInfo(i, access.TokenInfo{
+				Type:          string(id.TokenType),
+				Details:       id.Token,
+				Impersonation: id.Impersonation,
+			})
+			if id.Namespace != "" {
+				i = access.NamespacedUserInfo(i, id.Namespace, id.Backend)
+			}
+			if expiry := id.Expiry(); expiry.IsZero() {
+				cache.SetUserInfo(h.userCache, k, i)
+			} else {
+				cache.SetUserInfoUntil(h.userCache, k, i, expiry)
+			}
+			h.logger.Debug("Authentication succeeded", "user", i.Username, "backend", id.Backend)
 		}
-
-		i = access.UserInfo(u, g, *h.userInfo)
-		cache.SetUserInfo(h.userCache, t, i)
-		h.logger.Debug("Authentication succeeded", "user", i.Username)
 	} else {
 		i = cached.Value()
 		h.logger.Debug("Using cached authentication", "user", i.Username)
 		if i.UID == unauthorizedUsername { // previous rejection
 			h.logger.Info("Cached authentication failure", "user", i.Username)
-			h.stats.AuthNotFound(s)
+			h.stats.AuthNotFound(s, y)
 			h.rejectReview(w, m, "repeated authentication failure", http.StatusUnauthorized)
 			return
 		}
@@ -162,44 +234,95 @@ func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	err = h.authorize(r.Context(), s, i)
 	if err != nil {
 		h.logger.Info("Authorization failed", "user", i.Username, "realm", s, "err", err)
-		h.stats.AuthUnauthorized(s)
+		h.stats.AuthUnauthorized(s, y)
 		h.rejectReview(w, m, "precondition failed", http.StatusUnauthorized)
 		return
 	}
 
 	h.logger.Info("Authorization accepted", "user", i.Username, "realm", s)
-	h.stats.AuthSuccess(s)
+	h.stats.AuthSuccess(s, y)
 	h.acceptReview(w, m, i)
 }
 
-func (h *AuthHandler) authenticate(ctx context.Context, token string) (user *gitlab.User, groups []*gitlab.Group, err error) {
-	request := tracing.RequestIdentifierFromContext(ctx)
-	start := time.Now()
-	user, _, err = h.client.Users.CurrentUser(
-		gitlab.WithContext(ctx),
-		gitlab.WithToken(gitlab.PrivateToken, token),
-		gitlab.WithHeader(HeaderRequestId, request),
-	)
-	h.stats.GitlabRequest("users", time.Since(start))
-	if err != nil {
-		user = &gitlab.User{
-			Username: unauthorizedUsername,
-		}
-		return
+// userInfoOf converts the given identity into user information,
+// representing CI jobs as their pipeline.
+func (h *AuthHandler) userInfoOf(id *identity.Identity) authentication.UserInfo {
+	if id.Job == nil {
+		return access.UserInfo(id.User, id.Groups, *h.userInfoFor(id.Backend))
 	}
 
-	start = time.Now()
-	groups, _, err = h.client.Groups.ListGroups(h.listGroups,
-		gitlab.WithContext(ctx),
-		gitlab.WithToken(gitlab.PrivateToken, token),
-		gitlab.WithHeader(HeaderRequestId, request),
-	)
-	h.stats.GitlabRequest("groups", time.Since(start))
-	if err != nil {
-		return
+	job := access.PipelineInfo{
+		JobID:       id.Job.ID,
+		PipelineID:  id.Job.PipelineID,
+		Project:     id.Job.ProjectPath,
+		Namespace:   id.Job.Namespace,
+		Ref:         id.Job.Ref,
+		Protected:   id.Job.Protected,
+		Environment: id.Job.Environment,
+	}
+	if id.Job.User != nil {
+		job.TriggeredBy = id.Job.User.Username
 	}
 
-	return
+	return access.PipelineUserInfo(job)
+}
+
+func (h *AuthHandler) userInfoFor(backend string) *access.UserInfoOptions {
+	if opts, ok := h.backendUserInfo[backend]; ok {
+		return opts
+	}
+
+	return h.userInfo
+}
+
+// cacheKey returns the key under which the outcome of a lookup is
+// cached. Tokens are scoped if the identity source consults different
+// backends depending on the realm.
+func (h *AuthHandler) cacheKey(realm, token string) string {
+	scoper, ok := h.source.(identity.Scoper)
+	if !ok {
+		return token
+	}
+
+	scope := scoper.Scope(realm)
+	if scope == "" {
+		return token
+	}
+
+	return scope + "\x00" + token
+}
+
+// authenticateOnce calls the identity source unless
+// a lookup for the same key is already in progress, in which case
+// the result of the latter is awaited and returned instead.
+// The lookup is detached from the cancellation of the given context,
+// as the request which initiated it might be aborted while others
+// are still waiting for its result; it is bound by the lookup timeout
+// instead. Callers stop waiting once their own context is done.
+func (h *AuthHandler) authenticateOnce(ctx context.Context, realm, key, token string) (*identity.Identity, error) {
+	var leader bool
+	lookup := func() (interface{}, error) {
+		leader = true
+		ctx := context.WithoutCancel(ctx)
+		if h.lookupTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.lookupTimeout)
+			defer cancel()
+		}
+
+		return h.source.Lookup(identity.NewContextWithRealm(ctx, realm), token)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-h.flight.DoChan(h.userCache.Key(key), lookup):
+		if !leader {
+			h.stats.AuthCoalesced(realm)
+		}
+
+		return res.Val.(*identity.Identity), res.Err
+	}
 }
 
 func (h *AuthHandler) authorize(ctx context.Context, realm string, user authentication.UserInfo) error {
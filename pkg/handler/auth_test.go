@@ -0,0 +1,353 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	authentication "k8s.io/api/authentication/v1"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/cache"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/handler"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func review(h http.Handler, token string) *httptest.ResponseRecorder {
+	return reviewContext(context.Background(), h, token)
+}
+
+func reviewContext(ctx context.Context, h http.Handler, token string) *httptest.ResponseRecorder {
+	body := `{"apiVersion":"authentication.k8s.io/v1","kind":"TokenReview","spec":{"token":"` + token + `"}}`
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/authenticate", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAuthHandler(t *testing.T) {
+	tests := map[string]struct {
+		haveUser   *gitlab.User
+		haveGroups []*gitlab.Group
+		haveErr    error
+		wantStatus int
+		wantCalls  int
+		wantGroups string
+	}{
+		"success": {
+			haveUser:   &gitlab.User{ID: 7, Username: "jdoe"},
+			haveGroups: []*gitlab.Group{{FullPath: "infra/k8s"}},
+			wantStatus: http.StatusOK,
+			wantCalls:  1,
+			wantGroups: "infra:k8s",
+		},
+		"rejected": {
+			haveErr:    identity.ErrRejected,
+			wantStatus: http.StatusUnauthorized,
+			wantCalls:  1,
+		},
+		"unavailable": {
+			haveUser:   &gitlab.User{ID: 7, Username: "jdoe"},
+			haveErr:    identity.ErrServiceToken,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls int
+			source := identity.SourceFunc(func(_ context.Context, token string) (*identity.Identity, error) {
+				calls++
+				if token != "glpat-test" {
+					t.Errorf("Lookup(%q); want %q", token, "glpat-test")
+				}
+				if tt.haveUser == nil {
+					return nil, tt.haveErr
+				}
+				return &identity.Identity{User: tt.haveUser, Groups: tt.haveGroups}, tt.haveErr
+			})
+
+			stats, err := metrics.New(prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject, err := handler.NewAuthHandler(source, slog.New(slog.NewTextHandler(io.Discard, nil)),
+				handler.WithAuthMetrics(stats),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the second review is answered from the cache
+			// unless the first one failed due to a transient error
+			var rec *httptest.ResponseRecorder
+			for range 2 {
+				rec = review(subject, "glpat-test")
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d; want %d", rec.Code, tt.wantStatus)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("source calls = %d; want %d", calls, tt.wantCalls)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var result authentication.TokenReview
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+
+			got := result.Status.User
+			if got.Username != "jdoe" || got.UID != "7" {
+				t.Errorf("user = %q (%q); want %q (%q)", got.Username, got.UID, "jdoe", "7")
+			}
+			if strings.Join(got.Groups, ",") != tt.wantGroups {
+				t.Errorf("groups = %v; want %s", got.Groups, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestAuthHandlerMalformed(t *testing.T) {
+	source := identity.SourceFunc(func(context.Context, string) (*identity.Identity, error) {
+		t.Error("Lookup() called for malformed token")
+		return nil, errors.New("unexpected")
+	})
+	stats, err := metrics.New(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := handler.NewAuthHandler(source, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		handler.WithAuthMetrics(stats),
+		handler.WithAuthTokenValidator(func(v string) bool {
+			return strings.HasPrefix(v, "glpat-")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := review(subject, "ghp_test"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAuthHandlerStale(t *testing.T) {
+	outage := &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+	throttled := &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusTooManyRequests}}
+	tests := map[string]struct {
+		haveErrs    []error
+		wantStatus  []int
+		negativeTTL time.Duration
+	}{
+		"outage": {
+			haveErrs:   []error{outage},
+			wantStatus: []int{http.StatusOK},
+		},
+		"rate_limited": {
+			haveErrs:   []error{throttled, transport.ErrRateLimited},
+			wantStatus: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		},
+		"circuit_open": {
+			haveErrs:   []error{fmt.Errorf("Get: %w", transport.ErrCircuitOpen)},
+			wantStatus: []int{http.StatusOK},
+		},
+		"queue_timeout": {
+			haveErrs:   []error{transport.ErrQueueTimeout},
+			wantStatus: []int{http.StatusServiceUnavailable},
+		},
+		"rejected_then_outage": {
+			haveErrs:   []error{identity.ErrRejected, outage},
+			wantStatus: []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+		},
+		"rejected_then_outage_cached": {
+			haveErrs:    []error{identity.ErrRejected, outage},
+			wantStatus:  []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+			negativeTTL: time.Nanosecond,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls int
+			source := identity.SourceFunc(func(context.Context, string) (*identity.Identity, error) {
+				calls++
+				if calls == 1 {
+					return &identity.Identity{User: &gitlab.User{ID: 7, Username: "jdoe"}}, nil
+				}
+				return nil, tt.haveErrs[calls-2]
+			})
+
+			stats, err := metrics.New(prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject, err := handler.NewAuthHandler(source, slog.New(slog.NewTextHandler(io.Discard, nil)),
+				handler.WithAuthMetrics(stats),
+				handler.WithAuthNegativeCacheTTL(tt.negativeTTL),
+				handler.WithAuthUserCache(cache.NewUserInfoCache(cache.UserInfoCacheOpts{
+					TTL:         time.Nanosecond,
+					GracePeriod: time.Hour,
+				})),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rec := review(subject, "glpat-test"); rec.Code != http.StatusOK {
+				t.Fatalf("initial status = %d; want %d", rec.Code, http.StatusOK)
+			}
+
+			for i, want := range tt.wantStatus {
+				time.Sleep(time.Millisecond)
+				if rec := review(subject, "glpat-test"); rec.Code != want {
+					t.Errorf("status #%d = %d; want %d", i, rec.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthHandlerCoalesced(t *testing.T) {
+	const reviews = 5
+
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	source := identity.SourceFunc(func(ctx context.Context, _ string) (*identity.Identity, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &identity.Identity{User: &gitlab.User{ID: 7, Username: "jdoe"}}, nil
+	})
+
+	reg := prometheus.NewRegistry()
+	stats, err := metrics.New(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := handler.NewAuthHandler(source, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		handler.WithAuthMetrics(stats),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the leader gives up while the lookup is in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan int, 1)
+	go func() {
+		leader <- reviewContext(ctx, subject, "glpat-test").Code
+	}()
+	<-started
+
+	status := make(chan int, reviews-1)
+	var followers sync.WaitGroup
+	for range reviews - 1 {
+		followers.Add(1)
+		go func() {
+			defer followers.Done()
+			status <- review(subject, "glpat-test").Code
+		}()
+	}
+
+	// allow the followers to join the lookup in progress
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// the leader stops waiting without the lookup being released
+	if got := <-leader; got != http.StatusServiceUnavailable {
+		t.Errorf("leader status = %d; want %d", got, http.StatusServiceUnavailable)
+	}
+
+	close(release)
+	followers.Wait()
+
+	for range reviews - 1 {
+		if got := <-status; got != http.StatusOK {
+			t.Errorf("status = %d; want %d", got, http.StatusOK)
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("source calls = %d; want 1", got)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var coalesced float64
+	for _, f := range families {
+		if f.GetName() == "gitlab_authn_authentication_coalesced_total" {
+			for _, m := range f.GetMetric() {
+				coalesced += m.GetCounter().GetValue()
+			}
+		}
+	}
+	if coalesced != reviews-1 {
+		t.Errorf("coalesced = %v; want %d", coalesced, reviews-1)
+	}
+}
+
+func TestAuthHandlerLookupTimeout(t *testing.T) {
+	source := identity.SourceFunc(func(ctx context.Context, _ string) (*identity.Identity, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	stats, err := metrics.New(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := handler.NewAuthHandler(source, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		handler.WithAuthMetrics(stats),
+		handler.WithAuthLookupTimeout(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		done <- review(subject, "glpat-test").Code
+	}()
+
+	select {
+	case got := <-done:
+		if got != http.StatusServiceUnavailable {
+			t.Errorf("status = %d; want %d", got, http.StatusServiceUnavailable)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup not aborted after timeout")
+	}
+}
@@ -1,9 +1,13 @@
 package handler
 
+import (
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
+)
+
 const (
 	HeaderContentType        = "Content-Type"
 	HeaderLastModified       = "Last-Modified"
-	HeaderRequestId          = "X-Request-ID"
+	HeaderRequestId          = tracing.HeaderRequestId
 	HeaderContentTypeOptions = "X-Content-Type-Options"
 )
 
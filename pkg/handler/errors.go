@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+// IsCredentialRejection reports whether the given error is the result
+// of Gitlab refusing the provided credentials (401, 403, 404) or an
+// identity source reporting [identity.ErrRejected]. Any other
+// error (e.g. 429, 5xx, network failures) is considered transient, as
+// it does not allow any conclusion about the validity of the credentials.
+func IsCredentialRejection(err error) bool {
+	return identity.IsRejection(err)
+}
+
+// IsOutage reports whether the given error is the result of Gitlab
+// being unavailable, i.e. server errors (5xx), timeouts, network
+// failures, or requests refused by the circuit breaker
+// ([transport.ErrCircuitOpen]) due to any of the former.
+// Throttling (429, [transport.ErrRateLimited], [transport.ErrQueueTimeout])
+// is no outage, as Gitlab is reachable but asks for fewer requests.
+func IsOutage(err error) bool {
+	if err == nil || IsCredentialRejection(err) {
+		return false
+	}
+
+	if errors.Is(err, transport.ErrRateLimited) ||
+		errors.Is(err, transport.ErrQueueTimeout) ||
+		errors.Is(err, identity.ErrServiceToken) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, identity.ErrUnavailable) ||
+		errors.Is(err, transport.ErrCircuitOpen) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var resp *gitlab.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.Response != nil && resp.Response.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/handler"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestIsCredentialRejection(t *testing.T) {
+	response := func(code int) error {
+		return &gitlab.ErrorResponse{
+			Response: &http.Response{StatusCode: code},
+		}
+	}
+	tests := map[string]struct {
+		haveErr error
+		want    bool
+	}{
+		"not_found": {
+			haveErr: gitlab.ErrNotFound,
+			want:    true,
+		},
+		"unauthorized": {
+			haveErr: response(http.StatusUnauthorized),
+			want:    true,
+		},
+		"forbidden": {
+			haveErr: fmt.Errorf("wrapped: %w", response(http.StatusForbidden)),
+			want:    true,
+		},
+		"source_rejected": {
+			haveErr: fmt.Errorf("%w: 401 Unauthorized", identity.ErrRejected),
+			want:    true,
+		},
+		"service_token": {
+			haveErr: fmt.Errorf("%w: %v", identity.ErrServiceToken, response(http.StatusForbidden)),
+			want:    false,
+		},
+		"rate_limited": {
+			haveErr: response(http.StatusTooManyRequests),
+			want:    false,
+		},
+		"bad_gateway": {
+			haveErr: response(http.StatusBadGateway),
+			want:    false,
+		},
+		"timeout": {
+			haveErr: context.DeadlineExceeded,
+			want:    false,
+		},
+		"network": {
+			haveErr: errors.New("connection reset by peer"),
+			want:    false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := handler.IsCredentialRejection(test.haveErr); got != test.want {
+				t.Errorf("IsCredentialRejection(%v) = %t; want %t", test.haveErr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsOutage(t *testing.T) {
+	response := func(code int) error {
+		return &gitlab.ErrorResponse{
+			Response: &http.Response{StatusCode: code},
+		}
+	}
+	tests := map[string]struct {
+		haveErr error
+		want    bool
+	}{
+		"bad_gateway": {
+			haveErr: response(http.StatusBadGateway),
+			want:    true,
+		},
+		"source_unavailable": {
+			haveErr: fmt.Errorf("%w: 500 Internal Server Error", identity.ErrUnavailable),
+			want:    true,
+		},
+		"unreachable": {
+			haveErr: &url.Error{Op: "Get", URL: "https://gitlab.example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+			want:    true,
+		},
+		"timeout": {
+			haveErr: context.DeadlineExceeded,
+			want:    true,
+		},
+		"unauthorized": {
+			haveErr: response(http.StatusUnauthorized),
+			want:    false,
+		},
+		"too_many_requests": {
+			haveErr: response(http.StatusTooManyRequests),
+			want:    false,
+		},
+		"rate_limited": {
+			haveErr: &url.Error{Op: "Get", URL: "https://gitlab.example.com", Err: transport.ErrRateLimited},
+			want:    false,
+		},
+		"circuit_open": {
+			haveErr: &url.Error{Op: "Get", URL: "https://gitlab.example.com", Err: transport.ErrCircuitOpen},
+			want:    true,
+		},
+		"queue_timeout": {
+			haveErr: &url.Error{Op: "Get", URL: "https://gitlab.example.com", Err: transport.ErrQueueTimeout},
+			want:    false,
+		},
+		"service_token": {
+			haveErr: fmt.Errorf("%w: %v", identity.ErrServiceToken, errors.New("502 Bad Gateway")),
+			want:    false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := handler.IsOutage(test.haveErr); got != test.want {
+				t.Errorf("IsOutage(%v) = %t; want %t", test.haveErr, got, test.want)
+			}
+		})
+	}
+}
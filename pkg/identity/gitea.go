@@ -0,0 +1,278 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
+)
+
+// giteaPageSize is the number of items requested per page;
+// it matches the default maximum of Gitea (MAX_RESPONSE_ITEMS)
+const giteaPageSize = 50
+
+type giteaUser struct {
+	ID            int       `json:"id"`
+	Login         string    `json:"login"`
+	FullName      string    `json:"full_name"`
+	Email         string    `json:"email"`
+	AvatarURL     string    `json:"avatar_url"`
+	HTMLURL       string    `json:"html_url"`
+	IsAdmin       bool      `json:"is_admin"`
+	Active        bool      `json:"active"`
+	Restricted    bool      `json:"restricted"`
+	ProhibitLogin bool      `json:"prohibit_login"`
+	Visibility    string    `json:"visibility"`
+	LastLogin     time.Time `json:"last_login"`
+	Created       time.Time `json:"created"`
+}
+
+type giteaOrganization struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+}
+
+type giteaTeam struct {
+	ID           int                `json:"id"`
+	Name         string             `json:"name"`
+	Organization *giteaOrganization `json:"organization"`
+}
+
+// GiteaSource retrieves identities using the Gitea (or Forgejo) API.
+// Organizations and teams are reported as groups; the latter
+// using the organization name as parent (org/team).
+type GiteaSource struct {
+	client  *http.Client
+	baseURL *url.URL
+	logger  *slog.Logger
+	stats   *metrics.Metrics
+
+	groupPages int
+}
+
+// NewGiteaSource returns a source for the Gitea instance
+// located at the given URL. Unless configured otherwise,
+// measurements are discarded.
+func NewGiteaSource(client *http.Client, baseURL *url.URL, logger *slog.Logger, opts ...func(*GiteaSource)) (result *GiteaSource, err error) {
+	result = &GiteaSource{
+		client:  client,
+		baseURL: baseURL.JoinPath("api", "v1"),
+		logger:  logger,
+	}
+
+	for _, o := range opts {
+		o(result)
+	}
+
+	if result.stats == nil {
+		result.stats, err = metrics.New(prometheus.NewRegistry())
+	}
+
+	return
+}
+
+// WithGiteaPageLimit limits the number of organization and team
+// pages requested from Gitea. Values less than one disable the limit.
+func WithGiteaPageLimit(v int) func(*GiteaSource) {
+	return func(s *GiteaSource) {
+		s.groupPages = v
+	}
+}
+
+func WithGiteaMetrics(v *metrics.Metrics) func(*GiteaSource) {
+	return func(s *GiteaSource) {
+		s.stats = v
+	}
+}
+
+// Lookup retrieves the user, their organizations, and their teams
+// from Gitea. All lookups are performed concurrently.
+func (s *GiteaSource) Lookup(ctx context.Context, token string) (*Identity, error) {
+	var user giteaUser
+	var orgs []giteaOrganization
+	var teams []giteaTeam
+	var orgsTruncated, teamsTruncated bool
+
+	tasks, tasksCtx := errgroup.WithContext(ctx)
+	tasks.Go(func() error {
+		return s.get(tasksCtx, "users", token, "user", nil, &user)
+	})
+	tasks.Go(func() (err error) {
+		orgsTruncated, err = listAll(tasksCtx, s, token, "user/orgs", &orgs)
+		return
+	})
+	tasks.Go(func() (err error) {
+		teamsTruncated, err = listAll(tasksCtx, s, token, "user/teams", &teams)
+		return
+	})
+
+	// the first error is the root cause; subsequent ones
+	// are most likely the result of the cancellation
+	if err := tasks.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := user.toUser()
+	groups := make([]*gitlab.Group, 0, len(orgs)+len(teams))
+	for _, o := range orgs {
+		groups = append(groups, o.toGroup())
+	}
+	for _, t := range teams {
+		if g := t.toGroup(); g != nil {
+			groups = append(groups, g)
+		}
+	}
+
+	if orgsTruncated || teamsTruncated {
+		s.logger.Warn("Group memberships truncated by page limit", "user", result.Username, "limit", s.groupPages)
+		s.stats.GitlabGroupsTruncated()
+	}
+
+	return &Identity{User: result, Groups: groups}, nil
+}
+
+// listAll requests pages of the given collection until a page contains
+// less than the requested number of items or the page limit is reached.
+func listAll[T any](ctx context.Context, s *GiteaSource, token, path string, result *[]T) (truncated bool, err error) {
+	for page := 1; ; page++ {
+		if s.groupPages > 0 && page > s.groupPages {
+			return true, nil
+		}
+
+		query := url.Values{
+			"page":  []string{strconv.Itoa(page)},
+			"limit": []string{strconv.Itoa(giteaPageSize)},
+		}
+
+		var batch []T
+		if err = s.get(ctx, "groups", token, path, query, &batch); err != nil {
+			return
+		}
+
+		*result = append(*result, batch...)
+		if len(batch) < giteaPageSize {
+			return
+		}
+	}
+}
+
+func (s *GiteaSource) get(ctx context.Context, service, token, path string, query url.Values, v interface{}) error {
+	u := s.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	ctx = metrics.NewContextWithService(ctx, service)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "token "+token)
+	if request := tracing.RequestIdentifierFromContext(ctx); request != "" {
+		req.Header.Set(tracing.HeaderRequestId, request)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s %s", ErrRejected, req.URL.Path, resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: gitea request %s failed: %s", ErrUnavailable, req.URL.Path, resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("gitea request %s failed: %s", req.URL.Path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (u *giteaUser) toUser() *gitlab.User {
+	result := &gitlab.User{
+		ID:             u.ID,
+		Username:       u.Login,
+		Name:           u.FullName,
+		Email:          u.Email,
+		AvatarURL:      u.AvatarURL,
+		WebURL:         u.HTMLURL,
+		State:          "active",
+		IsAdmin:        u.IsAdmin,
+		External:       u.Restricted,
+		Locked:         u.ProhibitLogin,
+		PrivateProfile: u.Visibility == "private",
+	}
+
+	if u.ProhibitLogin {
+		result.State = "blocked"
+	}
+
+	if !u.Created.IsZero() {
+		result.CreatedAt = &u.Created
+	}
+
+	if u.Active {
+		// Gitea does not report the time of activation
+		result.ConfirmedAt = result.CreatedAt
+		if result.ConfirmedAt == nil {
+			result.ConfirmedAt = &time.Time{}
+		}
+	}
+
+	if !u.LastLogin.IsZero() {
+		activity := gitlab.ISOTime(u.LastLogin)
+		result.LastActivityOn = &activity
+	}
+
+	return result
+}
+
+func (o *giteaOrganization) toGroup() *gitlab.Group {
+	name := o.Username
+	if name == "" {
+		// older versions use the name field
+		name = o.Name
+	}
+
+	return &gitlab.Group{
+		ID:       o.ID,
+		Name:     name,
+		Path:     name,
+		FullName: o.FullName,
+		FullPath: name,
+	}
+}
+
+func (t *giteaTeam) toGroup() *gitlab.Group {
+	if t.Organization == nil {
+		return nil
+	}
+
+	org := t.Organization.toGroup()
+	return &gitlab.Group{
+		ID:       t.ID,
+		ParentID: org.ID,
+		Name:     t.Name,
+		Path:     t.Name,
+		FullName: org.Name + " / " + t.Name,
+		FullPath: org.FullPath + "/" + t.Name,
+	}
+}
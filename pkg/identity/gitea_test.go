@@ -0,0 +1,128 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+func newTestGitea(t *testing.T, orgCount int) *httptest.Server {
+	t.Helper()
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "token "+testUserToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			_, _ = io.WriteString(w, `{"id":3,"login":"jdoe","is_admin":true,"active":true,"restricted":true,"created":"2024-01-02T03:04:05Z"}`)
+		}
+	})
+	mux.HandleFunc("GET /api/v1/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		_, _ = io.WriteString(w, "[")
+		for i := (page - 1) * limit; i < min(page*limit, orgCount); i++ {
+			if i > (page-1)*limit {
+				_, _ = io.WriteString(w, ",")
+			}
+			_, _ = fmt.Fprintf(w, `{"id":%d,"username":"org-%d"}`, i, i)
+		}
+		_, _ = io.WriteString(w, "]")
+	})
+	mux.HandleFunc("GET /api/v1/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			_, _ = io.WriteString(w, `[{"id":9,"name":"Owners","organization":{"id":0,"username":"org-0"}}]`)
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGiteaSource(t *testing.T) {
+	tests := map[string]struct {
+		orgs       int
+		pageLimit  int
+		wantGroups int
+	}{
+		"single_page": {
+			orgs:       2,
+			wantGroups: 3,
+		},
+		"multiple_pages": {
+			orgs:       75,
+			wantGroups: 76,
+		},
+		"truncated": {
+			orgs:       75,
+			pageLimit:  1,
+			wantGroups: 51,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestGitea(t, tt.orgs)
+			defer server.Close()
+
+			baseURL, _ := url.Parse(server.URL)
+			subject, err := identity.NewGiteaSource(server.Client(), baseURL, testLogger,
+				identity.WithGiteaPageLimit(tt.pageLimit),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), testUserToken)
+			if err != nil {
+				t.Fatalf("Lookup() = %v; want nil", err)
+			}
+
+			user, groups := id.User, id.Groups
+
+			if user.Username != "jdoe" || user.ID != 3 || !user.IsAdmin || !user.External || user.ConfirmedAt == nil {
+				t.Errorf("user = %+v; want confirmed, restricted admin jdoe (3)", user)
+			}
+
+			if len(groups) != tt.wantGroups {
+				t.Errorf("len(groups) = %d; want %d", len(groups), tt.wantGroups)
+			}
+
+			if got := groups[len(groups)-1].FullPath; got != "org-0/Owners" {
+				t.Errorf("team path = %q; want %q", got, "org-0/Owners")
+			}
+		})
+	}
+}
+
+func TestGiteaSourceRejected(t *testing.T) {
+	server := newTestGitea(t, 1)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	subject, err := identity.NewGiteaSource(server.Client(), baseURL, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := subject.Lookup(context.Background(), "invalid"); !errors.Is(err, identity.ErrRejected) {
+		t.Errorf("Lookup() = %v; want %v", err, identity.ErrRejected)
+	}
+}
@@ -0,0 +1,421 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
+)
+
+// GitlabSource retrieves identities using the Gitlab API.
+type GitlabSource struct {
+	client *gitlab.Client
+	logger *slog.Logger
+	stats  *metrics.Metrics
+
+	listGroups *gitlab.ListGroupsOptions
+	groupPages int
+
+	serviceToken  string
+	graphQL       bool
+	introspection bool
+	tokenTypes    TokenTypes
+}
+
+// NewGitlabSource returns a source using the given client.
+// Unless configured otherwise, measurements are discarded.
+func NewGitlabSource(client *gitlab.Client, logger *slog.Logger, opts ...func(*GitlabSource)) (result *GitlabSource, err error) {
+	result = &GitlabSource{
+		client:     client,
+		logger:     logger,
+		listGroups: new(gitlab.ListGroupsOptions),
+	}
+
+	for _, o := range opts {
+		o(result)
+	}
+
+	if result.stats == nil {
+		result.stats, err = metrics.New(prometheus.NewRegistry())
+	}
+
+	return
+}
+
+func WithGitlabGroupFilter(v *gitlab.ListGroupsOptions) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.listGroups = v
+	}
+}
+
+// WithGitlabGroupPageLimit limits the number of group pages
+// requested from Gitlab. Values less than one disable the limit.
+func WithGitlabGroupPageLimit(v int) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.groupPages = v
+	}
+}
+
+// WithGitlabServiceToken enables the service account mode.
+// The presented token is only used to identify the user;
+// the user record, their custom attributes, and group memberships
+// are retrieved using the given (administrator) token instead.
+// The mode is disabled if the value is empty.
+func WithGitlabServiceToken(v string) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.serviceToken = v
+	}
+}
+
+// WithGitlabGraphQL retrieves the user and their group memberships
+// using the Gitlab GraphQL API instead of the REST API.
+func WithGitlabGraphQL(v bool) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.graphQL = v
+	}
+}
+
+// WithGitlabTokenIntrospection retrieves the details (scopes, expiration)
+// of the presented token alongside the user.
+func WithGitlabTokenIntrospection(v bool) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.introspection = v
+	}
+}
+
+// WithGitlabTokenTypes defines the authentication scheme of tokens
+// based on their prefix. Unless configured otherwise, all tokens are
+// presented as [TokenTypePrivate].
+func WithGitlabTokenTypes(v TokenTypes) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.tokenTypes = v
+	}
+}
+
+func WithGitlabMetrics(v *metrics.Metrics) func(*GitlabSource) {
+	return func(s *GitlabSource) {
+		s.stats = v
+	}
+}
+
+// Lookup retrieves the user and their group memberships
+// from Gitlab. Both lookups are performed concurrently; if the
+// user lookup fails, the group lookup is cancelled and its result
+// discarded. If enabled, the token is introspected at the same time.
+// CI job tokens are resolved to the job they belong to instead.
+func (s *GitlabSource) Lookup(ctx context.Context, token string) (*Identity, error) {
+	if s.tokenTypes.Of(token) == TokenTypeJob {
+		job, err := s.lookupJob(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Identity{Job: job, TokenType: TokenTypeJob}, nil
+	}
+
+	var user *gitlab.User
+	var groups []*gitlab.Group
+	var details *gitlab.PersonalAccessToken
+	var err, detailsErr error
+
+	introspection := new(errgroup.Group)
+	if s.introspection {
+		introspection.Go(func() error {
+			details, detailsErr = s.currentToken(ctx, token)
+			return nil
+		})
+	}
+
+	switch {
+	case s.serviceToken != "":
+		user, groups, err = s.lookupService(ctx, token)
+	case s.graphQL:
+		user, groups, err = s.lookupGraphQL(ctx, token)
+	default:
+		user, groups, err = s.lookupREST(ctx, token)
+	}
+
+	_ = introspection.Wait()
+	if user == nil {
+		return nil, err
+	}
+
+	if err == nil && s.introspection {
+		details, err = s.checkToken(user, details, detailsErr)
+	}
+
+	var impersonation *bool
+	if err == nil && details != nil && s.serviceToken != "" {
+		var ok bool
+		if ok, err = s.impersonationToken(ctx, user.ID, details.ID); err == nil {
+			impersonation = &ok
+		}
+	}
+
+	result := &Identity{
+		User:          user,
+		Groups:        groups,
+		Token:         details,
+		TokenType:     s.tokenTypes.Of(token),
+		Impersonation: impersonation,
+	}
+	return result, err
+}
+
+// impersonationToken determines whether the given token has been
+// issued by an administrator to impersonate the user. Gitlab only
+// reveals this to administrators, i.e. using the service token.
+func (s *GitlabSource) impersonationToken(ctx context.Context, userID, tokenID int) (bool, error) {
+	ctx = metrics.NewContextWithService(ctx, "tokens")
+	request := tracing.RequestIdentifierFromContext(ctx)
+
+	start := time.Now()
+	_, _, err := s.client.Users.GetImpersonationToken(userID, tokenID,
+		gitlab.WithContext(ctx),
+		gitlab.WithToken(gitlab.PrivateToken, s.serviceToken),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrServiceToken, err)
+	}
+
+	return true, nil
+}
+
+// checkToken evaluates the outcome of the token introspection.
+// Gitlab does not provide details for tokens other than personal,
+// group, and project access tokens (and older versions do not support
+// introspection at all), which is why rejections are not fatal.
+func (s *GitlabSource) checkToken(user *gitlab.User, details *gitlab.PersonalAccessToken, err error) (*gitlab.PersonalAccessToken, error) {
+	if err != nil && IsRejection(err) {
+		s.logger.Debug("Token details are unavailable", "user", user.Username, "err", err)
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if details.Revoked || !details.Active {
+		return details, fmt.Errorf("%w: token %d is no longer active", ErrRejected, details.ID)
+	}
+
+	return details, nil
+}
+
+func (s *GitlabSource) lookupREST(ctx context.Context, token string) (user *gitlab.User, groups []*gitlab.Group, err error) {
+	var userErr error
+	var truncated bool
+
+	tasks, tasksCtx := errgroup.WithContext(ctx)
+	tasks.Go(func() error {
+		user, userErr = s.currentUser(tasksCtx, token)
+		return userErr
+	})
+	tasks.Go(func() (err error) {
+		groups, truncated, err = s.listAllGroups(tasksCtx, s.tokenTypes.WithToken(token))
+		return
+	})
+
+	// the first error is the root cause; subsequent ones
+	// are most likely the result of the cancellation
+	err = tasks.Wait()
+	if userErr != nil {
+		return nil, nil, err
+	}
+
+	if err != nil {
+		return
+	}
+
+	s.checkTruncated(user, truncated)
+
+	return
+}
+
+// lookupService identifies the user using the given token
+// and retrieves the remaining information using the service token.
+// Failures of the latter are not the fault of the user and are
+// therefore reported as [ErrServiceToken] instead of the Gitlab
+// response, to prevent them from being treated as credential rejection.
+func (s *GitlabSource) lookupService(ctx context.Context, token string) (user *gitlab.User, groups []*gitlab.Group, err error) {
+	var truncated bool
+
+	user, err = s.currentUser(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, username := user.ID, user.Username
+	auth := gitlab.WithToken(gitlab.PrivateToken, s.serviceToken)
+	tasks, tasksCtx := errgroup.WithContext(ctx)
+	tasks.Go(func() (err error) {
+		user, err = s.getUser(tasksCtx, id, auth)
+		return
+	})
+	tasks.Go(func() (err error) {
+		groups, truncated, err = s.listAllGroups(tasksCtx, auth, gitlab.WithSudo(id))
+		return
+	})
+
+	if err = tasks.Wait(); err != nil {
+		err = fmt.Errorf("%w: %v", ErrServiceToken, err)
+		user = &gitlab.User{
+			ID:       id,
+			Username: username,
+		}
+		return user, nil, err
+	}
+
+	s.checkTruncated(user, truncated)
+
+	return
+}
+
+func (s *GitlabSource) checkTruncated(user *gitlab.User, truncated bool) {
+	if truncated {
+		s.logger.Warn("Group memberships truncated by page limit", "user", user.Username, "limit", s.groupPages)
+		s.stats.GitlabGroupsTruncated()
+	}
+}
+
+func (s *GitlabSource) currentUser(ctx context.Context, token string) (*gitlab.User, error) {
+	ctx = metrics.NewContextWithService(ctx, "users")
+	request := tracing.RequestIdentifierFromContext(ctx)
+
+	start := time.Now()
+	user, _, err := s.client.Users.CurrentUser(
+		gitlab.WithContext(ctx),
+		s.tokenTypes.WithToken(token),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	return user, err
+}
+
+// currentToken retrieves the details of the given token.
+func (s *GitlabSource) currentToken(ctx context.Context, token string) (*gitlab.PersonalAccessToken, error) {
+	ctx = metrics.NewContextWithService(ctx, "tokens")
+	request := tracing.RequestIdentifierFromContext(ctx)
+
+	start := time.Now()
+	details, _, err := s.client.PersonalAccessTokens.GetSinglePersonalAccessToken(
+		gitlab.WithContext(ctx),
+		s.tokenTypes.WithToken(token),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	return details, err
+}
+
+// getUser retrieves the full user record including custom attributes.
+func (s *GitlabSource) getUser(ctx context.Context, id int, auth gitlab.RequestOptionFunc) (*gitlab.User, error) {
+	ctx = metrics.NewContextWithService(ctx, "users")
+	request := tracing.RequestIdentifierFromContext(ctx)
+	opts := gitlab.GetUsersOptions{
+		WithCustomAttributes: gitlab.Ptr(true),
+	}
+
+	start := time.Now()
+	user, _, err := s.client.Users.GetUser(id, opts,
+		gitlab.WithContext(ctx),
+		auth,
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	return user, err
+}
+
+// listAllGroups follows the pagination of the Gitlab groups API.
+// Pages are requested in parallel if Gitlab reports the total number
+// of pages, otherwise the next page links are followed one by one.
+// The returned flag indicates whether the result has been cut short
+// due to the configured page limit. The request options are expected
+// to provide the authentication.
+func (s *GitlabSource) listAllGroups(ctx context.Context, auth ...gitlab.RequestOptionFunc) (groups []*gitlab.Group, truncated bool, err error) {
+	groups, resp, err := s.listGroupsPage(ctx, 1, auth...)
+	if err != nil || resp.NextPage == 0 {
+		return
+	}
+
+	if resp.TotalPages == 0 {
+		// Gitlab omits the pagination totals for large collections
+		for page := resp.NextPage; page > 0; page = resp.NextPage {
+			if s.groupPages > 0 && page > s.groupPages {
+				truncated = true
+				return
+			}
+
+			var batch []*gitlab.Group
+			batch, resp, err = s.listGroupsPage(ctx, page, auth...)
+			if err != nil {
+				return
+			}
+
+			groups = append(groups, batch...)
+		}
+
+		return
+	}
+
+	last := resp.TotalPages
+	if s.groupPages > 0 && last > s.groupPages {
+		last = s.groupPages
+		truncated = true
+	}
+
+	batches := make([][]*gitlab.Group, last-1)
+	tasks, tasksCtx := errgroup.WithContext(ctx)
+	tasks.SetLimit(groupPageConcurrency)
+	for i := range batches {
+		tasks.Go(func() (err error) {
+			batches[i], _, err = s.listGroupsPage(tasksCtx, i+2, auth...)
+			return
+		})
+	}
+
+	if err = tasks.Wait(); err != nil {
+		return
+	}
+
+	for _, batch := range batches {
+		groups = append(groups, batch...)
+	}
+
+	return
+}
+
+func (s *GitlabSource) listGroupsPage(ctx context.Context, page int, auth ...gitlab.RequestOptionFunc) ([]*gitlab.Group, *gitlab.Response, error) {
+	ctx = metrics.NewContextWithService(ctx, "groups")
+	request := tracing.RequestIdentifierFromContext(ctx)
+	opts := *s.listGroups
+	opts.Page = page
+	options := append([]gitlab.RequestOptionFunc{
+		gitlab.WithContext(ctx),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	}, auth...)
+
+	start := time.Now()
+	groups, resp, err := s.client.Groups.ListGroups(&opts, options...)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	return groups, resp, err
+}
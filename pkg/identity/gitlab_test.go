@@ -0,0 +1,436 @@
+package identity_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+const (
+	testUserToken    = "glpat-user"
+	testServiceToken = "glpat-service"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestClient(t *testing.T, server *httptest.Server) *gitlab.Client {
+	t.Helper()
+
+	client, err := gitlab.NewClient("", gitlab.WithBaseURL(server.URL), gitlab.WithoutRetries())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// newTestGitlab returns a fake Gitlab API which only identifies
+// users using their own token and requires the service token
+// (and impersonation for groups) for everything else.
+func newTestGitlab(t *testing.T, serviceStatus int) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != testUserToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":7,"username":"jdoe"}`)
+	})
+	mux.HandleFunc("GET /api/v4/users/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != testServiceToken || serviceStatus != http.StatusOK {
+			w.WriteHeader(serviceStatus)
+			return
+		}
+		if r.URL.Query().Get("with_custom_attributes") != "true" {
+			t.Errorf("custom attributes not requested: %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `{"id":7,"username":"jdoe","is_admin":true,"custom_attributes":[{"key":"team","value":"ops"}]}`)
+	})
+	mux.HandleFunc("GET /api/v4/groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != testServiceToken || serviceStatus != http.StatusOK {
+			w.WriteHeader(serviceStatus)
+			return
+		}
+		if sudo := r.Header.Get("Sudo"); sudo != "7" {
+			t.Errorf("Sudo = %q; want %q", sudo, "7")
+		}
+		_, _ = io.WriteString(w, `[{"id":1,"full_path":"infra/k8s"}]`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGitlabSourceServiceToken(t *testing.T) {
+	tests := map[string]struct {
+		token         string
+		serviceStatus int
+		wantErr       error
+		wantUser      string
+	}{
+		"success": {
+			token:         testUserToken,
+			serviceStatus: http.StatusOK,
+			wantUser:      "jdoe",
+		},
+		"service_rejected": {
+			token:         testUserToken,
+			serviceStatus: http.StatusForbidden,
+			wantErr:       identity.ErrServiceToken,
+			wantUser:      "jdoe",
+		},
+		"user_rejected": {
+			token:         "glpat-invalid",
+			serviceStatus: http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestGitlab(t, tt.serviceStatus)
+			defer server.Close()
+
+			subject, err := identity.NewGitlabSource(newTestClient(t, server), testLogger,
+				identity.WithGitlabServiceToken(testServiceToken),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), tt.token)
+			if tt.wantUser == "" {
+				if err == nil || id != nil {
+					t.Fatalf("Lookup() = %v, %v; want rejection", id, err)
+				}
+				return
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Lookup() error = %v; want %v", err, tt.wantErr)
+				}
+				if got := id.Username(""); got != tt.wantUser {
+					t.Errorf("Lookup() user = %q; want %q for logging", got, tt.wantUser)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Lookup() = %v; want nil", err)
+			}
+
+			user, groups := id.User, id.Groups
+			if user.Username != tt.wantUser || !user.IsAdmin {
+				t.Errorf("user = %q (admin: %v); want %q (admin: true)", user.Username, user.IsAdmin, tt.wantUser)
+			}
+			if len(user.CustomAttributes) != 1 || user.CustomAttributes[0].Value != "ops" {
+				t.Errorf("custom attributes = %v; want team=ops", user.CustomAttributes)
+			}
+			if len(groups) != 1 || groups[0].FullPath != "infra/k8s" {
+				t.Errorf("groups = %v; want [infra/k8s]", groups)
+			}
+		})
+	}
+}
+
+func TestGitlabSourceTokenIntrospection(t *testing.T) {
+	tests := map[string]struct {
+		tokenStatus int
+		tokenBody   string
+		wantErr     error
+		wantToken   bool
+	}{
+		"active": {
+			tokenStatus: http.StatusOK,
+			tokenBody:   `{"id":42,"name":"k8s","scopes":["read_user"],"active":true,"expires_at":"2030-01-01"}`,
+			wantToken:   true,
+		},
+		"revoked": {
+			tokenStatus: http.StatusOK,
+			tokenBody:   `{"id":42,"name":"k8s","revoked":true,"active":false}`,
+			wantErr:     identity.ErrRejected,
+		},
+		"unsupported": {
+			tokenStatus: http.StatusNotFound,
+			tokenBody:   `{"message":"404 Not Found"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `{"id":7,"username":"jdoe"}`)
+			})
+			mux.HandleFunc("GET /api/v4/groups", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `[]`)
+			})
+			mux.HandleFunc("GET /api/v4/personal_access_tokens/self", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Private-Token") != testUserToken {
+					t.Errorf("token introspected with %q", r.Header.Get("Private-Token"))
+				}
+				w.WriteHeader(tt.tokenStatus)
+				_, _ = io.WriteString(w, tt.tokenBody)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			subject, err := identity.NewGitlabSource(newTestClient(t, server), testLogger,
+				identity.WithGitlabTokenIntrospection(true),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), testUserToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup() = %v; want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if (id.Token != nil) != tt.wantToken {
+				t.Fatalf("Lookup() token = %v; want details: %v", id.Token, tt.wantToken)
+			}
+
+			if tt.wantToken && id.Expiry().Format("2006-01-02") != "2030-01-01" {
+				t.Errorf("Expiry() = %v; want 2030-01-01", id.Expiry())
+			}
+
+			// only revealed to the service token
+			if id.Impersonation != nil {
+				t.Errorf("Lookup() impersonation = %v; want unknown", *id.Impersonation)
+			}
+		})
+	}
+}
+
+func TestGitlabSourceGroupPagination(t *testing.T) {
+	tests := map[string]struct {
+		totals        bool
+		pageLimit     int
+		wantGroups    string
+		wantTruncated float64
+	}{
+		"parallel": {
+			totals:     true,
+			wantGroups: "group:1,group:2,group:3",
+		},
+		"parallel_truncated": {
+			totals:        true,
+			pageLimit:     2,
+			wantGroups:    "group:1,group:2",
+			wantTruncated: 1,
+		},
+		"sequential": {
+			wantGroups: "group:1,group:2,group:3",
+		},
+		"sequential_truncated": {
+			pageLimit:     2,
+			wantGroups:    "group:1,group:2",
+			wantTruncated: 1,
+		},
+	}
+
+	const pages = 3
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requested []string
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `{"id":7,"username":"jdoe"}`)
+			})
+			mux.HandleFunc("GET /api/v4/groups", func(w http.ResponseWriter, r *http.Request) {
+				page, err := strconv.Atoi(r.URL.Query().Get("page"))
+				if err != nil || page < 1 || page > pages {
+					t.Errorf("unexpected page requested: %s", r.URL.RawQuery)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				mu.Lock()
+				requested = append(requested, strconv.Itoa(page))
+				mu.Unlock()
+
+				if page < pages {
+					w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+				}
+				if tt.totals {
+					w.Header().Set("X-Total-Pages", strconv.Itoa(pages))
+				}
+				_, _ = fmt.Fprintf(w, `[{"id":%d,"full_path":"group/%d"}]`, page, page)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			reg := prometheus.NewRegistry()
+			stats, err := metrics.New(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var logs bytes.Buffer
+			subject, err := identity.NewGitlabSource(newTestClient(t, server),
+				slog.New(slog.NewTextHandler(&logs, nil)),
+				identity.WithGitlabGroupPageLimit(tt.pageLimit),
+				identity.WithGitlabMetrics(stats),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), testUserToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			groups := make([]string, len(id.Groups))
+			for i, g := range id.Groups {
+				groups[i] = strings.ReplaceAll(g.FullPath, "/", ":")
+			}
+			if got := strings.Join(groups, ","); got != tt.wantGroups {
+				t.Errorf("groups = %s; want %s", got, tt.wantGroups)
+			}
+
+			if want := len(groups); len(requested) != want {
+				t.Errorf("requested pages = %v; want %d", requested, want)
+			}
+
+			if got := counterValue(t, reg, "gitlab_authn_gitlab_groups_truncated_total"); got != tt.wantTruncated {
+				t.Errorf("truncated metric = %v; want %v", got, tt.wantTruncated)
+			}
+
+			warned := strings.Contains(logs.String(), "level=WARN msg=\"Group memberships truncated by page limit\"")
+			if want := tt.wantTruncated > 0; warned != want {
+				t.Errorf("truncation warning logged = %v; want %v\n%s", warned, want, logs.String())
+			}
+		})
+	}
+}
+
+func TestGitlabSourceRequestAttempts(t *testing.T) {
+	var mu sync.Mutex
+	var failures int
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures < 2 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":7,"username":"jdoe"}`)
+	})
+	mux.HandleFunc("GET /api/v4/groups", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `[{"id":1,"full_path":"infra/k8s"}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	retrier := transport.NewRetrier(transport.RetryOpts{
+		MaxRetries: 2,
+		OnRetry: func(r *http.Request, _ int) {
+			metrics.RetryInContext(r.Context())
+		},
+	})
+	httpClient := &http.Client{
+		Transport: retrier.Middleware()(http.DefaultTransport),
+	}
+	client, err := gitlab.NewClient("",
+		gitlab.WithBaseURL(server.URL),
+		gitlab.WithHTTPClient(httpClient),
+		gitlab.WithoutRetries(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	stats, err := metrics.New(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := identity.NewGitlabSource(client, testLogger,
+		identity.WithGitlabMetrics(stats),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := subject.Lookup(context.Background(), testUserToken); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]uint64{
+		"users/3":  1,
+		"groups/1": 1,
+	}
+	got := map[string]uint64{}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "gitlab_authn_gitlab_request_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			got[labels["service"]+"/"+labels["attempts"]] = m.GetHistogram().GetSampleCount()
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("request samples = %v; want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("request samples %s = %d; want %d", k, got[k], v)
+		}
+	}
+}
+
+// counterValue returns the value of the unlabeled counter
+// with the given name from the given registry.
+func counterValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range families {
+		if f.GetName() == name && len(f.GetMetric()) > 0 {
+			return f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
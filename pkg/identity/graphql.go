@@ -0,0 +1,283 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
+)
+
+// graphQLGroupPageSize is the number of group memberships requested
+// per query if the group filter does not specify a limit
+const graphQLGroupPageSize = 100
+
+// graphQLUserQuery retrieves the current user along with
+// one page of their group memberships.
+const graphQLUserQuery = `query($first: Int, $after: String) {
+  currentUser {
+    id
+    username
+    name
+    state
+    bot
+    publicEmail
+    webUrl
+    avatarUrl
+    lastActivityOn
+    groupMemberships(first: $first, after: $after) {
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      nodes {
+        accessLevel {
+          integerValue
+        }
+        group {
+          id
+          name
+          path
+          fullName
+          fullPath
+        }
+      }
+    }
+  }
+}`
+
+type graphQLRequest struct {
+	Query     string `url:"query"`
+	Variables string `url:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data struct {
+		CurrentUser *graphQLUser `json:"currentUser"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type graphQLUser struct {
+	ID               string `json:"id"`
+	Username         string `json:"username"`
+	Name             string `json:"name"`
+	State            string `json:"state"`
+	Bot              bool   `json:"bot"`
+	PublicEmail      string `json:"publicEmail"`
+	WebURL           string `json:"webUrl"`
+	AvatarURL        string `json:"avatarUrl"`
+	LastActivityOn   string `json:"lastActivityOn"`
+	GroupMemberships struct {
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Nodes []struct {
+			AccessLevel struct {
+				IntegerValue int `json:"integerValue"`
+			} `json:"accessLevel"`
+			Group *graphQLGroup `json:"group"`
+		} `json:"nodes"`
+	} `json:"groupMemberships"`
+}
+
+type graphQLGroup struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	FullName string `json:"fullName"`
+	FullPath string `json:"fullPath"`
+}
+
+// lookupGraphQL retrieves the user and their group memberships
+// using the Gitlab GraphQL API. The first page of memberships is part
+// of the user query; subsequent pages are requested using the cursor
+// of the previous one. The group filter is applied to the result, as
+// the GraphQL API does not support the same filter criteria.
+func (s *GitlabSource) lookupGraphQL(ctx context.Context, token string) (user *gitlab.User, groups []*gitlab.Group, err error) {
+	var truncated bool
+	var cursor string
+
+	for page := 1; ; page++ {
+		var result *graphQLUser
+		result, err = s.queryGraphQL(ctx, token, cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if user == nil {
+			user = result.toUser()
+		}
+
+		for _, m := range result.GroupMemberships.Nodes {
+			if m.Group != nil && s.acceptGroup(m.Group, m.AccessLevel.IntegerValue) {
+				groups = append(groups, m.Group.toGroup())
+			}
+		}
+
+		if !result.GroupMemberships.PageInfo.HasNextPage {
+			break
+		}
+
+		if s.groupPages > 0 && page >= s.groupPages {
+			truncated = true
+			break
+		}
+
+		cursor = result.GroupMemberships.PageInfo.EndCursor
+	}
+
+	s.checkTruncated(user, truncated)
+
+	return
+}
+
+func (s *GitlabSource) queryGraphQL(ctx context.Context, token, cursor string) (*graphQLUser, error) {
+	ctx = metrics.NewContextWithService(ctx, "graphql")
+	request := tracing.RequestIdentifierFromContext(ctx)
+	variables := map[string]interface{}{
+		"first": graphQLGroupPageSize,
+	}
+	if s.listGroups.PerPage > 0 {
+		variables["first"] = s.listGroups.PerPage
+	}
+	if cursor != "" {
+		variables["after"] = cursor
+	}
+
+	vars, err := json.Marshal(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &graphQLRequest{
+		Query:     graphQLUserQuery,
+		Variables: string(vars),
+	}
+	// queries are sent via GET to benefit from retries
+	req, err := s.client.NewRequest(http.MethodGet, "", opts, []gitlab.RequestOptionFunc{
+		gitlab.WithContext(ctx),
+		s.tokenTypes.WithToken(token),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+		withGraphQLEndpoint(s.client),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp graphQLResponse
+	start := time.Now()
+	_, err = s.client.Do(req, &resp)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql query failed: %s", resp.Errors[0].Message)
+	}
+
+	if resp.Data.CurrentUser == nil {
+		// Gitlab answers anonymous queries, i.e. invalid tokens
+		// are only noticeable by the absence of the current user
+		return nil, gitlab.ErrNotFound
+	}
+
+	return resp.Data.CurrentUser, nil
+}
+
+// acceptGroup applies the group filter to a single membership.
+func (s *GitlabSource) acceptGroup(g *graphQLGroup, accessLevel int) bool {
+	f := s.listGroups
+	if f.MinAccessLevel != nil && accessLevel < int(*f.MinAccessLevel) {
+		return false
+	}
+
+	if f.Owned != nil && *f.Owned && accessLevel < int(gitlab.OwnerPermissions) {
+		return false
+	}
+
+	if f.TopLevelOnly != nil && *f.TopLevelOnly && strings.Contains(g.FullPath, "/") {
+		return false
+	}
+
+	if f.Search != nil && *f.Search != "" {
+		search := strings.ToLower(*f.Search)
+		if !strings.Contains(strings.ToLower(g.Name), search) &&
+			!strings.Contains(strings.ToLower(g.FullPath), search) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (u *graphQLUser) toUser() *gitlab.User {
+	result := &gitlab.User{
+		ID:          parseGlobalID(u.ID),
+		Username:    u.Username,
+		Name:        u.Name,
+		State:       u.State,
+		Bot:         u.Bot,
+		PublicEmail: u.PublicEmail,
+		WebURL:      u.WebURL,
+		AvatarURL:   u.AvatarURL,
+	}
+
+	if t, err := time.Parse("2006-01-02", u.LastActivityOn); err == nil {
+		activity := gitlab.ISOTime(t)
+		result.LastActivityOn = &activity
+	}
+
+	return result
+}
+
+func (g *graphQLGroup) toGroup() *gitlab.Group {
+	return &gitlab.Group{
+		ID:       parseGlobalID(g.ID),
+		Name:     g.Name,
+		Path:     g.Path,
+		FullName: g.FullName,
+		FullPath: g.FullPath,
+	}
+}
+
+// parseGlobalID extracts the numeric identifier
+// from a GraphQL global ID (gid://gitlab/User/1).
+func parseGlobalID(gid string) int {
+	id, err := strconv.Atoi(gid[strings.LastIndex(gid, "/")+1:])
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// withGraphQLEndpoint redirects the request to the GraphQL endpoint,
+// which resides next to the REST API base path (/api/v4/ → /api/graphql).
+func withGraphQLEndpoint(client *gitlab.Client) gitlab.RequestOptionFunc {
+	endpoint := path.Join(path.Dir(strings.TrimSuffix(client.BaseURL().Path, "/")), "graphql")
+
+	return func(req *retryablehttp.Request) error {
+		if req.URL == nil {
+			return errors.New("graphql request without URL")
+		}
+
+		req.URL.Path = endpoint
+		req.URL.RawPath = ""
+		return nil
+	}
+}
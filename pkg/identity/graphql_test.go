@@ -0,0 +1,81 @@
+package identity_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+func TestGitlabSourceGraphQL(t *testing.T) {
+	pages := map[string]string{
+		"": `{"data":{"currentUser":{"id":"gid://gitlab/User/7","username":"jdoe","state":"active",
+			"groupMemberships":{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},"nodes":[
+				{"accessLevel":{"integerValue":50},"group":{"id":"gid://gitlab/Group/1","fullPath":"infra"}},
+				{"accessLevel":{"integerValue":10},"group":{"id":"gid://gitlab/Group/2","fullPath":"guests"}}]}}}}`,
+		"c1": `{"data":{"currentUser":{"id":"gid://gitlab/User/7","username":"jdoe","state":"active",
+			"groupMemberships":{"pageInfo":{"hasNextPage":false,"endCursor":"c2"},"nodes":[
+				{"accessLevel":{"integerValue":30},"group":{"id":"gid://gitlab/Group/3","fullPath":"infra/k8s"}}]}}}}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != testUserToken {
+			_, _ = io.WriteString(w, `{"data":{"currentUser":null}}`)
+			return
+		}
+
+		var vars struct {
+			After string `json:"after"`
+		}
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("variables")), &vars); err != nil {
+			t.Errorf("invalid variables: %v", err)
+		}
+		_, _ = io.WriteString(w, pages[vars.After])
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	subject, err := identity.NewGitlabSource(newTestClient(t, server), testLogger,
+		identity.WithGitlabGraphQL(true),
+		identity.WithGitlabGroupFilter(&gitlab.ListGroupsOptions{
+			MinAccessLevel: gitlab.Ptr(gitlab.DeveloperPermissions),
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := subject.Lookup(context.Background(), "glpat-invalid"); !errors.Is(err, gitlab.ErrNotFound) {
+		t.Errorf("Lookup(invalid) = %v; want %v", err, gitlab.ErrNotFound)
+	}
+
+	id, err := subject.Lookup(context.Background(), testUserToken)
+	if err != nil {
+		t.Fatalf("Lookup() = %v; want nil", err)
+	}
+
+	user, groups := id.User, id.Groups
+
+	if user.Username != "jdoe" || user.ID != 7 {
+		t.Errorf("user = %q (%d); want %q (%d)", user.Username, user.ID, "jdoe", 7)
+	}
+
+	if user.ConfirmedAt != nil {
+		t.Errorf("user confirmed at %v; want unknown", user.ConfirmedAt)
+	}
+
+	var paths []string
+	for _, g := range groups {
+		paths = append(paths, g.FullPath)
+	}
+	if len(paths) != 2 || paths[0] != "infra" || paths[1] != "infra/k8s" {
+		t.Errorf("groups = %v; want [infra infra/k8s]", paths)
+	}
+}
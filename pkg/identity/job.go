@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/metrics"
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/tracing"
+)
+
+// Job describes the CI job a job token has been issued for.
+type Job struct {
+	// ID of the job
+	ID int
+	// ID of the pipeline the job is part of
+	PipelineID int
+	// ID of the project running the pipeline
+	ProjectID int
+	// Full path of the project (e.g. platform/deploy)
+	ProjectPath string
+	// Full path of the namespace the project resides in
+	Namespace string
+	// Branch or tag the pipeline runs for
+	Ref string
+	// Whether Ref is a tag
+	Tag bool
+	// Whether Ref is a protected branch or tag;
+	// only determined in service account mode.
+	Protected bool
+	// Name of the deployment environment;
+	// empty for jobs not deploying anywhere.
+	Environment string
+	// User who triggered the job; might be nil
+	User *gitlab.User
+}
+
+// pipelineJob is the response of the job token API.
+// Deployment jobs include the environment they target.
+type pipelineJob struct {
+	gitlab.Job
+
+	Environment *struct {
+		Name string `json:"name"`
+	} `json:"environment"`
+}
+
+// lookupJob identifies the CI job the given job token belongs to.
+// The protection status of the ref can only be retrieved with the
+// service token, as job tokens have no access to the project settings.
+func (s *GitlabSource) lookupJob(ctx context.Context, token string) (*Job, error) {
+	details, err := s.currentJob(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if details.Project == nil {
+		return nil, fmt.Errorf("details of job %d lack the project", details.ID)
+	}
+
+	result := &Job{
+		ID:          details.ID,
+		PipelineID:  details.Pipeline.ID,
+		ProjectID:   details.Project.ID,
+		ProjectPath: details.Project.PathWithNamespace,
+		Ref:         details.Ref,
+		Tag:         details.Tag,
+		User:        details.User,
+	}
+	if details.Project.Namespace != nil {
+		result.Namespace = details.Project.Namespace.FullPath
+	}
+	if details.Environment != nil {
+		result.Environment = details.Environment.Name
+	}
+
+	if s.serviceToken == "" {
+		s.logger.Debug("Ref protection is unknown without service token", "project", result.ProjectPath, "ref", result.Ref)
+		return result, nil
+	}
+
+	result.Protected, err = s.protectedRef(ctx, result.ProjectID, result.Ref, result.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// currentJob retrieves the details of the job the given token belongs to.
+func (s *GitlabSource) currentJob(ctx context.Context, token string) (*pipelineJob, error) {
+	ctx = metrics.NewContextWithService(ctx, "jobs")
+	request := tracing.RequestIdentifierFromContext(ctx)
+
+	req, err := s.client.NewRequest(http.MethodGet, "job", nil, []gitlab.RequestOptionFunc{
+		gitlab.WithContext(ctx),
+		gitlab.WithToken(gitlab.JobToken, token),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result := new(pipelineJob)
+	_, err = s.client.Do(req, result)
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// protectedRef determines whether the given branch or tag is protected
+// using the service token. Failures are reported as [ErrServiceToken].
+func (s *GitlabSource) protectedRef(ctx context.Context, pid int, ref string, tag bool) (bool, error) {
+	ctx = metrics.NewContextWithService(ctx, "projects")
+	request := tracing.RequestIdentifierFromContext(ctx)
+	options := []gitlab.RequestOptionFunc{
+		gitlab.WithContext(ctx),
+		gitlab.WithToken(gitlab.PrivateToken, s.serviceToken),
+		gitlab.WithHeader(tracing.HeaderRequestId, request),
+	}
+
+	var err error
+	start := time.Now()
+	if tag {
+		_, _, err = s.client.ProtectedTags.GetProtectedTag(pid, ref, options...)
+	} else {
+		_, _, err = s.client.ProtectedBranches.GetProtectedBranch(pid, ref, options...)
+	}
+	s.stats.GitlabRequest(ctx, time.Since(start))
+
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrServiceToken, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+const testJobToken = "glcbt-job"
+
+func TestGitlabSourceJobToken(t *testing.T) {
+	tests := map[string]struct {
+		serviceToken    string
+		protectedStatus int
+		wantErr         error
+		wantProtected   bool
+	}{
+		"without_service_token": {
+			protectedStatus: http.StatusOK,
+		},
+		"protected": {
+			serviceToken:    testServiceToken,
+			protectedStatus: http.StatusOK,
+			wantProtected:   true,
+		},
+		"unprotected": {
+			serviceToken:    testServiceToken,
+			protectedStatus: http.StatusNotFound,
+		},
+		"service_rejected": {
+			serviceToken:    testServiceToken,
+			protectedStatus: http.StatusForbidden,
+			wantErr:         identity.ErrServiceToken,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v4/job", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Job-Token"); got != testJobToken {
+					t.Errorf("Job-Token = %q; want %q", got, testJobToken)
+				}
+				_, _ = io.WriteString(w, `{"id":99,"ref":"main","pipeline":{"id":9},`+
+					`"project":{"id":5,"path_with_namespace":"platform/deploy","namespace":{"full_path":"platform"}},`+
+					`"environment":{"name":"production"},"user":{"id":7,"username":"jdoe"}}`)
+			})
+			mux.HandleFunc("GET /api/v4/projects/5/protected_branches/main", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Private-Token"); got != testServiceToken {
+					t.Errorf("Private-Token = %q; want %q", got, testServiceToken)
+				}
+				w.WriteHeader(tt.protectedStatus)
+				_, _ = io.WriteString(w, `{"name":"main"}`)
+			})
+			mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("user requested for job token")
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			subject, err := identity.NewGitlabSource(newTestClient(t, server), testLogger,
+				identity.WithGitlabServiceToken(tt.serviceToken),
+				identity.WithGitlabTokenTypes(identity.TokenTypes{"glcbt-": identity.TokenTypeJob}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), testJobToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup() = %v; want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			job := id.Job
+			if job == nil || id.TokenType != identity.TokenTypeJob {
+				t.Fatalf("Lookup() = %+v; want job identity", id)
+			}
+			if job.ProjectPath != "platform/deploy" || job.Namespace != "platform" || job.Ref != "main" {
+				t.Errorf("job = %q (%q) @ %q; want platform/deploy (platform) @ main", job.ProjectPath, job.Namespace, job.Ref)
+			}
+			if job.Environment != "production" || job.User == nil || job.User.Username != "jdoe" {
+				t.Errorf("job environment = %q, user = %v; want production, jdoe", job.Environment, job.User)
+			}
+			if job.Protected != tt.wantProtected {
+				t.Errorf("job protected = %v; want %v", job.Protected, tt.wantProtected)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type realmContextKey struct{}
+
+// NewContextWithRealm returns a copy of the given context
+// carrying the name of the realm a lookup is performed for.
+func NewContextWithRealm(ctx context.Context, realm string) context.Context {
+	return context.WithValue(ctx, realmContextKey{}, realm)
+}
+
+// RealmFromContext returns the realm stored in the given context
+// by [NewContextWithRealm] or an empty string if there is none.
+func RealmFromContext(ctx context.Context) string {
+	realm, _ := ctx.Value(realmContextKey{}).(string)
+	return realm
+}
+
+// Scoper is implemented by sources whose results
+// depend on the realm a lookup is performed for.
+type Scoper interface {
+	// Scope returns an identifier for the set of forges
+	// consulted on behalf of the given realm. Lookups with
+	// the same token and scope yield the same result.
+	Scope(realm string) string
+}
+
+// Backend is a named forge taking part in the routing of lookups.
+type Backend struct {
+	// Name identifies the backend in logs and results.
+	Name string
+	// Namespace is prepended to identities from this backend.
+	Namespace string
+	// Source performs the actual lookup.
+	Source Source
+	// Prefixes of the tokens issued by this backend;
+	// tokens with a different prefix are never sent to it.
+	Prefixes []string
+	// Realms bound to this backend. Lookups on behalf of these
+	// realms are exclusively routed to the bound backends.
+	Realms []string
+	// Fallback permits tokens to be passed on to this backend
+	// after a preceding candidate failed to recognize them.
+	Fallback bool
+}
+
+func (b *Backend) accepts(token string) bool {
+	for _, p := range b.Prefixes {
+		if strings.HasPrefix(token, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Router is a [Source] dispatching lookups to one of several backends.
+// Candidates are the backends bound to the realm of the lookup (or all
+// of them, if the realm has no binding) which accept the prefix of the
+// token. The first candidate is always consulted; the remaining ones
+// are tried in order until one recognizes the token, unless they have
+// not opted into [Backend.Fallback], in which case they are skipped.
+// This prevents tokens from being disclosed to other forges merely
+// because their prefixes overlap.
+type Router struct {
+	backends []*Backend
+}
+
+// NewRouter creates a router consulting the given backends in order.
+func NewRouter(backends ...*Backend) *Router {
+	return &Router{
+		backends: backends,
+	}
+}
+
+// Scope returns the names of the backends bound to the given realm
+// or an empty string if the realm is not bound to any backend.
+func (r *Router) Scope(realm string) string {
+	return strings.Join(r.bound(realm), ",")
+}
+
+// Lookup tries the candidate backends in order. Rejections cause the
+// next fallback candidate to be consulted; transient errors are only
+// reported if none of the remaining candidates recognize the token either.
+func (r *Router) Lookup(ctx context.Context, token string) (*Identity, error) {
+	bound := r.bound(RealmFromContext(ctx))
+	var rejected, transient error
+	var failed *Identity
+	var consulted bool
+
+	for _, b := range r.backends {
+		if len(bound) > 0 && !slices.Contains(bound, b.Name) {
+			continue
+		}
+		if !b.accepts(token) {
+			continue
+		}
+		if consulted && !b.Fallback {
+			continue
+		}
+		consulted = true
+
+		id, err := b.Source.Lookup(ctx, token)
+		if id != nil {
+			id.Backend = b.Name
+			id.Namespace = b.Namespace
+		}
+
+		if err == nil {
+			return id, nil
+		}
+
+		if ctx.Err() != nil {
+			return id, err
+		}
+
+		if IsRejection(err) {
+			rejected = err
+		} else {
+			transient = err
+			failed = id
+		}
+	}
+
+	if transient != nil {
+		return failed, transient
+	}
+
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	return nil, fmt.Errorf("%w: no backend accepts the token", ErrRejected)
+}
+
+func (r *Router) bound(realm string) (names []string) {
+	for _, b := range r.backends {
+		if slices.Contains(b.Realms, realm) {
+			names = append(names, b.Name)
+		}
+	}
+
+	return
+}
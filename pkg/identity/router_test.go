@@ -0,0 +1,129 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+// fakeBackend answers lookups for a single token
+// and records the number of calls it received.
+func fakeBackend(name, token string, prefixes []string, calls map[string]int, err error) *identity.Backend {
+	source := identity.SourceFunc(func(_ context.Context, t string) (*identity.Identity, error) {
+		calls[name]++
+		if err != nil {
+			return nil, err
+		}
+		if t != token {
+			return nil, identity.ErrRejected
+		}
+		return &identity.Identity{User: &gitlab.User{Username: name}}, nil
+	})
+
+	return &identity.Backend{
+		Name:      name,
+		Namespace: name + ":",
+		Source:    source,
+		Prefixes:  prefixes,
+	}
+}
+
+func TestRouter(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	tests := map[string]struct {
+		token        string
+		realm        string
+		corpErr      error
+		saasFallback bool
+		wantBackend  string
+		wantErr      error
+		wantCalls    map[string]int
+	}{
+		"prefix": {
+			token:       "corp-abc",
+			wantBackend: "corp",
+			wantCalls:   map[string]int{"corp": 1},
+		},
+		"fallback": {
+			token:        "glpat-saas",
+			saasFallback: true,
+			wantBackend:  "saas",
+			wantCalls:    map[string]int{"self": 1, "saas": 1},
+		},
+		"no fallback": {
+			token:     "glpat-saas",
+			wantErr:   identity.ErrRejected,
+			wantCalls: map[string]int{"self": 1},
+		},
+		"realm binding": {
+			token:       "glpat-saas",
+			realm:       "internal",
+			wantErr:     identity.ErrRejected,
+			wantCalls:   map[string]int{"self": 1},
+			wantBackend: "",
+		},
+		"no candidate": {
+			token:     "ghp_abc",
+			wantErr:   identity.ErrRejected,
+			wantCalls: map[string]int{},
+		},
+		"transient": {
+			token:     "corp-abc",
+			corpErr:   errUnavailable,
+			wantErr:   errUnavailable,
+			wantCalls: map[string]int{"corp": 1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := map[string]int{}
+			self := fakeBackend("self", "glpat-self", []string{"glpat-"}, calls, nil)
+			self.Realms = []string{"internal"}
+			saas := fakeBackend("saas", "glpat-saas", []string{"glpat-"}, calls, nil)
+			saas.Fallback = tt.saasFallback
+			subject := identity.NewRouter(
+				fakeBackend("corp", "corp-abc", []string{"corp-"}, calls, tt.corpErr),
+				self,
+				saas,
+			)
+
+			ctx := identity.NewContextWithRealm(context.Background(), tt.realm)
+			id, err := subject.Lookup(ctx, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup() = %v; want %v", err, tt.wantErr)
+			}
+
+			if tt.wantBackend != "" {
+				if id.Backend != tt.wantBackend || id.Namespace != tt.wantBackend+":" {
+					t.Errorf("backend = %q (%q); want %q", id.Backend, id.Namespace, tt.wantBackend)
+				}
+			}
+
+			if len(calls) != len(tt.wantCalls) {
+				t.Errorf("calls = %v; want %v", calls, tt.wantCalls)
+			}
+			for k, v := range tt.wantCalls {
+				if calls[k] != v {
+					t.Errorf("calls[%s] = %d; want %d", k, calls[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterScope(t *testing.T) {
+	a := &identity.Backend{Name: "a", Realms: []string{"internal"}}
+	b := &identity.Backend{Name: "b", Realms: []string{"internal", "ops"}}
+	subject := identity.NewRouter(a, b)
+
+	for realm, want := range map[string]string{"": "", "internal": "a,b", "ops": "b"} {
+		if got := subject.Scope(realm); got != want {
+			t.Errorf("Scope(%q) = %q; want %q", realm, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package identity retrieves user information
+// from forges using the tokens presented for review.
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+var (
+	// ErrRejected is reported by sources if the
+	// forge refused to accept the presented token.
+	ErrRejected = errors.New("Token rejected")
+	// ErrServiceToken is reported by sources if a lookup using
+	// the service token failed. Such failures are not the fault
+	// of the user and therefore no credential rejection.
+	ErrServiceToken = errors.New("Service token lookup failed")
+	// ErrUnavailable is reported by sources if the forge
+	// failed to process a request due to a server error.
+	ErrUnavailable = errors.New("Forge unavailable")
+)
+
+// groupPageConcurrency is the number of group pages
+// requested from the forge at the same time
+const groupPageConcurrency = 4
+
+// Identity is the result of a lookup.
+// Users and groups are represented using the Gitlab data model,
+// regardless of the forge they originate from.
+type Identity struct {
+	User   *gitlab.User
+	Groups []*gitlab.Group
+	// CI job the presented job token belongs to;
+	// User and Groups are not populated for jobs.
+	Job *Job
+	// Details of the presented token; nil if the
+	// forge does not support token introspection.
+	Token *gitlab.PersonalAccessToken
+	// Authentication scheme of the presented token;
+	// empty if the forge does not distinguish them.
+	TokenType TokenType
+	// Whether the presented token is an impersonation token;
+	// nil if the forge does not reveal it.
+	Impersonation *bool
+	// Name of the forge the identity originates from;
+	// empty if only a single forge is configured.
+	Backend string
+	// Namespace of the username, UID, and groups;
+	// empty if they are to be used as-is.
+	Namespace string
+}
+
+// Username returns the name of the user or
+// the given fallback if no user is available.
+func (i *Identity) Username(fallback string) string {
+	if i == nil || i.User == nil {
+		return fallback
+	}
+
+	return i.User.Username
+}
+
+// Expiry returns the time at which the presented token
+// expires or the zero time if the expiration is unknown.
+func (i *Identity) Expiry() time.Time {
+	if i == nil || i.Token == nil || i.Token.ExpiresAt == nil {
+		return time.Time{}
+	}
+
+	// tokens expire at midnight UTC of the given date
+	return time.Time(*i.Token.ExpiresAt)
+}
+
+// Source retrieves identities from a forge.
+type Source interface {
+	// Lookup returns the user associated with the given token
+	// along with their group memberships. If an error is returned,
+	// the identity might still be provided to name the subject
+	// of the failed lookup.
+	Lookup(ctx context.Context, token string) (*Identity, error)
+}
+
+// SourceFunc is an adapter to allow the use of
+// ordinary functions as [Source].
+type SourceFunc func(context.Context, string) (*Identity, error)
+
+// Lookup calls f(ctx, token).
+func (f SourceFunc) Lookup(ctx context.Context, token string) (*Identity, error) {
+	return f(ctx, token)
+}
+
+// IsRejection reports whether the given error is the result
+// of the forge refusing the provided credentials (401, 403, 404)
+// or [ErrRejected]. Any other error (e.g. 429, 5xx, network failures)
+// is considered transient, as it does not allow any conclusion about
+// the validity of the credentials.
+func IsRejection(err error) bool {
+	if errors.Is(err, gitlab.ErrNotFound) || errors.Is(err, ErrRejected) {
+		return true
+	}
+
+	var resp *gitlab.ErrorResponse
+	if !errors.As(err, &resp) || resp.Response == nil {
+		return false
+	}
+
+	switch resp.Response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+
+	return false
+}
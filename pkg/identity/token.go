@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"strings"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// TokenType denotes the authentication scheme a token
+// has to be presented with.
+type TokenType string
+
+const (
+	// TokenTypePrivate tokens are sent using the PRIVATE-TOKEN header
+	// (personal, group, project, and impersonation tokens)
+	TokenTypePrivate TokenType = "private"
+	// TokenTypeOAuth tokens are sent as bearer token
+	TokenTypeOAuth TokenType = "oauth"
+	// TokenTypeJob tokens are sent using the JOB-TOKEN header
+	TokenTypeJob TokenType = "job"
+)
+
+// AuthType returns the Gitlab authentication scheme of the token type.
+func (t TokenType) AuthType() gitlab.AuthType {
+	switch t {
+	case TokenTypeOAuth:
+		return gitlab.OAuthToken
+	case TokenTypeJob:
+		return gitlab.JobToken
+	default:
+		return gitlab.PrivateToken
+	}
+}
+
+// Valid reports whether the token type is known.
+func (t TokenType) Valid() bool {
+	switch t {
+	case TokenTypePrivate, TokenTypeOAuth, TokenTypeJob:
+		return true
+	}
+
+	return false
+}
+
+// TokenTypes maps token prefixes onto their token type.
+type TokenTypes map[string]TokenType
+
+// Of returns the type associated with the longest prefix
+// of the given token or [TokenTypePrivate] if there is none.
+func (t TokenTypes) Of(token string) TokenType {
+	result, length := TokenTypePrivate, -1
+	for prefix, typ := range t {
+		if len(prefix) > length && strings.HasPrefix(token, prefix) {
+			result, length = typ, len(prefix)
+		}
+	}
+
+	return result
+}
+
+// WithToken returns the request option presenting
+// the given token using the scheme of its type.
+func (t TokenTypes) WithToken(token string) gitlab.RequestOptionFunc {
+	return gitlab.WithToken(t.Of(token).AuthType(), token)
+}
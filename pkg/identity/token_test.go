@@ -0,0 +1,84 @@
+package identity_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/identity"
+)
+
+func TestTokenTypesOf(t *testing.T) {
+	subject := identity.TokenTypes{
+		"gl":     identity.TokenTypeJob,
+		"gloas-": identity.TokenTypeOAuth,
+	}
+	tests := map[string]identity.TokenType{
+		"gloas-abc": identity.TokenTypeOAuth,
+		"glcbt-abc": identity.TokenTypeJob,
+		"abc":       identity.TokenTypePrivate,
+	}
+
+	for token, want := range tests {
+		if got := subject.Of(token); got != want {
+			t.Errorf("Of(%q) = %q; want %q", token, got, want)
+		}
+	}
+}
+
+func TestGitlabSourceTokenTypes(t *testing.T) {
+	tests := map[string]struct {
+		token      string
+		wantHeader string
+		wantValue  string
+		wantType   identity.TokenType
+	}{
+		"private": {
+			token:      "glpat-abc",
+			wantHeader: "Private-Token",
+			wantValue:  "glpat-abc",
+			wantType:   identity.TokenTypePrivate,
+		},
+		"oauth": {
+			token:      "gloas-abc",
+			wantHeader: "Authorization",
+			wantValue:  "Bearer gloas-abc",
+			wantType:   identity.TokenTypeOAuth,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get(tt.wantHeader); got != tt.wantValue {
+					t.Errorf("%s = %q; want %q", tt.wantHeader, got, tt.wantValue)
+				}
+				_, _ = io.WriteString(w, `{"id":7,"username":"jdoe"}`)
+			})
+			mux.HandleFunc("GET /api/v4/groups", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `[]`)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			subject, err := identity.NewGitlabSource(newTestClient(t, server), testLogger,
+				identity.WithGitlabTokenTypes(identity.TokenTypes{"gloas-": identity.TokenTypeOAuth}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := subject.Lookup(context.Background(), tt.token)
+			if err != nil {
+				t.Fatalf("Lookup() = %v; want nil", err)
+			}
+
+			if id.TokenType != tt.wantType {
+				t.Errorf("TokenType = %q; want %q", id.TokenType, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type serviceContextKey int
+
+const serviceKey serviceContextKey = 0
+
+type serviceRequest struct {
+	service string
+	retries atomic.Int64
+}
+
+// NewContextWithService returns a new Context
+// that carries the given Gitlab service name.
+func NewContextWithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceKey, &serviceRequest{service: service})
+}
+
+// ServiceFromContext returns the Gitlab service name stored in ctx,
+// or an empty string if none exists.
+func ServiceFromContext(ctx context.Context) string {
+	req, ok := ctx.Value(serviceKey).(*serviceRequest)
+	if ok {
+		return req.service
+	}
+
+	return ""
+}
+
+// RetryInContext records a repeated request against
+// the Gitlab service stored in ctx. It is a no-op
+// if ctx carries no service.
+func RetryInContext(ctx context.Context) {
+	req, ok := ctx.Value(serviceKey).(*serviceRequest)
+	if ok {
+		req.retries.Add(1)
+	}
+}
+
+// AttemptsFromContext returns the number of requests sent
+// to the Gitlab service stored in ctx, including retries.
+func AttemptsFromContext(ctx context.Context) int {
+	req, ok := ctx.Value(serviceKey).(*serviceRequest)
+	if ok {
+		return 1 + int(req.retries.Load())
+	}
+
+	return 1
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,6 +21,18 @@ var (
 		Name:      "attempts_total",
 		Help:      "Number of authentication attempts.",
 	}
+	optsAuthCoalesced = prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "authentication",
+		Name:      "coalesced_total",
+		Help:      "Number of authentication attempts served by a concurrent Gitlab lookup.",
+	}
+	optsAuthStale = prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "authentication",
+		Name:      "stale_total",
+		Help:      "Number of authentication attempts served from expired cache entries.",
+	}
 	optsGitlabDuration = prometheus.HistogramOpts{
 		Namespace: Namespace,
 		Subsystem: "gitlab",
@@ -26,18 +40,44 @@ var (
 		Help:      "Elapsed time in seconds for HTTP request against Gitlab.",
 		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10},
 	}
+	optsGitlabQueueWait = prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "gitlab",
+		Name:      "request_queue_wait_seconds",
+		Help:      "Elapsed time in seconds HTTP requests waited for a free slot.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}
+	optsGitlabInfo = prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "gitlab",
+		Name:      "info",
+		Help:      "Version information of the Gitlab instance; constant 1.",
+	}
+	optsGitlabTruncated = prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "gitlab",
+		Name:      "groups_truncated_total",
+		Help:      "Number of group listings cut short by the page limit.",
+	}
 )
 
 const (
-	labelRealm   = "realm"
-	labelCause   = "cause"
-	labelService = "service"
+	labelRealm    = "realm"
+	labelCause    = "cause"
+	labelService  = "service"
+	labelAttempts = "attempts"
+	labelVersion  = "version"
+	labelRevision = "revision"
+	labelEdition  = "edition"
+	labelBackend  = "backend"
+	labelType     = "type"
 )
 
 const (
 	authCauseMalformed    = "malformed"
 	authCauseNotFound     = "not_found"
 	authCauseUnauthorized = "unauthorized"
+	authCauseUnavailable  = "unavailable"
 )
 
 // Metrics is an abstraction over several measurement trackers.
@@ -45,9 +85,17 @@ const (
 // this implementation exposes a simplified API for application
 // specific scenarios.
 type Metrics struct {
-	authFailures   *prometheus.CounterVec
-	authAttempts   *prometheus.CounterVec
-	gitlabDuration *prometheus.HistogramVec
+	authFailures    *prometheus.CounterVec
+	authAttempts    *prometheus.CounterVec
+	authCoalesced   *prometheus.CounterVec
+	authStale       *prometheus.CounterVec
+	gitlabDuration  *prometheus.HistogramVec
+	gitlabQueueWait *prometheus.HistogramVec
+	gitlabTruncated *prometheus.CounterVec
+	gitlabInfo      *prometheus.GaugeVec
+
+	// name of the Gitlab instance the per-backend metrics are labeled with
+	backend string
 }
 
 // NewDefault calls [New] with [prometheus.DefaultRegisterer]
@@ -61,25 +109,55 @@ func NewDefault() (*Metrics, error) {
 func New(reg prometheus.Registerer) (*Metrics, error) {
 	authFailures := prometheus.NewCounterVec(
 		optsAuthFailures,
-		[]string{labelRealm, labelCause},
+		[]string{labelRealm, labelType, labelCause},
 	)
 	authAttempts := prometheus.NewCounterVec(
 		optsAuthAttempts,
+		[]string{labelRealm, labelType},
+	)
+	authCoalesced := prometheus.NewCounterVec(
+		optsAuthCoalesced,
+		[]string{labelRealm},
+	)
+	authStale := prometheus.NewCounterVec(
+		optsAuthStale,
 		[]string{labelRealm},
 	)
 	gitlabDuration := prometheus.NewHistogramVec(
 		optsGitlabDuration,
-		[]string{labelService},
+		[]string{labelService, labelAttempts},
+	)
+	gitlabQueueWait := prometheus.NewHistogramVec(
+		optsGitlabQueueWait,
+		[]string{labelBackend},
+	)
+	gitlabTruncated := prometheus.NewCounterVec(
+		optsGitlabTruncated,
+		[]string{labelBackend},
+	)
+	gitlabInfo := prometheus.NewGaugeVec(
+		optsGitlabInfo,
+		[]string{labelBackend, labelVersion, labelRevision, labelEdition},
 	)
 	collectors := []prometheus.Collector{
 		authFailures,
 		authAttempts,
+		authCoalesced,
+		authStale,
 		gitlabDuration,
+		gitlabQueueWait,
+		gitlabTruncated,
+		gitlabInfo,
 	}
 	result := &Metrics{
-		authFailures:   authFailures,
-		authAttempts:   authAttempts,
-		gitlabDuration: gitlabDuration,
+		authFailures:    authFailures,
+		authAttempts:    authAttempts,
+		authCoalesced:   authCoalesced,
+		authStale:       authStale,
+		gitlabDuration:  gitlabDuration,
+		gitlabQueueWait: gitlabQueueWait,
+		gitlabTruncated: gitlabTruncated,
+		gitlabInfo:      gitlabInfo,
 	}
 
 	for _, c := range collectors {
@@ -92,33 +170,91 @@ func New(reg prometheus.Registerer) (*Metrics, error) {
 }
 
 // AuthSuccess tracks a successful authentication.
-func (m *Metrics) AuthSuccess(realm string) {
-	m.authAttempts.With(prometheus.Labels{labelRealm: realm}).Inc()
+func (m *Metrics) AuthSuccess(realm, tokenType string) {
+	m.authAttempts.With(prometheus.Labels{labelRealm: realm, labelType: tokenType}).Inc()
 }
 
 // AuthMalformed tracks a failed authentication
 // due to malformed user input.
-func (m *Metrics) AuthMalformed(realm string) {
-	m.authAttempts.With(prometheus.Labels{labelRealm: realm}).Inc()
-	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelCause: authCauseMalformed}).Inc()
+func (m *Metrics) AuthMalformed(realm, tokenType string) {
+	m.authAttempts.With(prometheus.Labels{labelRealm: realm, labelType: tokenType}).Inc()
+	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelType: tokenType, labelCause: authCauseMalformed}).Inc()
 }
 
 // AuthNotFound tracks a failed authentication
 // due to a lack of account information associated with the user input.
-func (m *Metrics) AuthNotFound(realm string) {
-	m.authAttempts.With(prometheus.Labels{labelRealm: realm}).Inc()
-	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelCause: authCauseNotFound}).Inc()
+func (m *Metrics) AuthNotFound(realm, tokenType string) {
+	m.authAttempts.With(prometheus.Labels{labelRealm: realm, labelType: tokenType}).Inc()
+	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelType: tokenType, labelCause: authCauseNotFound}).Inc()
 }
 
 // AuthUnauthorized tracks a failed authentication
 // due to the user not being authorized to
 // access the provided realm.
-func (m *Metrics) AuthUnauthorized(realm string) {
-	m.authAttempts.With(prometheus.Labels{labelRealm: realm}).Inc()
-	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelCause: authCauseUnauthorized}).Inc()
+func (m *Metrics) AuthUnauthorized(realm, tokenType string) {
+	m.authAttempts.With(prometheus.Labels{labelRealm: realm, labelType: tokenType}).Inc()
+	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelType: tokenType, labelCause: authCauseUnauthorized}).Inc()
+}
+
+// AuthUnavailable tracks a failed authentication
+// due to Gitlab being unable to answer the request.
+func (m *Metrics) AuthUnavailable(realm, tokenType string) {
+	m.authAttempts.With(prometheus.Labels{labelRealm: realm, labelType: tokenType}).Inc()
+	m.authFailures.With(prometheus.Labels{labelRealm: realm, labelType: tokenType, labelCause: authCauseUnavailable}).Inc()
+}
+
+// AuthCoalesced tracks an authentication attempt
+// which shared the Gitlab lookup of a concurrent attempt.
+func (m *Metrics) AuthCoalesced(realm string) {
+	m.authCoalesced.With(prometheus.Labels{labelRealm: realm}).Inc()
+}
+
+// AuthStale tracks an authentication attempt served
+// from an expired cache entry due to Gitlab being unavailable.
+func (m *Metrics) AuthStale(realm string) {
+	m.authStale.With(prometheus.Labels{labelRealm: realm}).Inc()
+}
+
+// GitlabRequest reports on the elapsed time for the Gitlab service
+// stored in ctx, along with the number of attempts it took.
+func (m *Metrics) GitlabRequest(ctx context.Context, elapsed time.Duration) {
+	labels := prometheus.Labels{
+		labelService:  ServiceFromContext(ctx),
+		labelAttempts: strconv.Itoa(AttemptsFromContext(ctx)),
+	}
+	m.gitlabDuration.With(labels).Observe(elapsed.Seconds())
+}
+
+// Backend returns a copy of the metrics abstraction layer
+// whose per-backend metrics are labeled with the given name
+// of a Gitlab instance.
+func (m *Metrics) Backend(name string) *Metrics {
+	result := *m
+	result.backend = name
+
+	return &result
+}
+
+// GitlabQueueWait reports on the elapsed time a request
+// waited for a free slot before being sent to Gitlab.
+func (m *Metrics) GitlabQueueWait(elapsed time.Duration) {
+	m.gitlabQueueWait.With(prometheus.Labels{labelBackend: m.backend}).Observe(elapsed.Seconds())
+}
+
+// GitlabGroupsTruncated tracks a group listing
+// which has been cut short due to the page limit.
+func (m *Metrics) GitlabGroupsTruncated() {
+	m.gitlabTruncated.With(prometheus.Labels{labelBackend: m.backend}).Inc()
 }
 
-// GitlabRequest reports on the elapsed time for the specific Gitlab service.
-func (m *Metrics) GitlabRequest(service string, elapsed time.Duration) {
-	m.gitlabDuration.With(prometheus.Labels{labelService: service}).Observe(elapsed.Seconds())
+// GitlabInfo records the version information of the given Gitlab instance.
+// Previously recorded information about the instance is discarded.
+func (m *Metrics) GitlabInfo(backend, version, revision, edition string) {
+	m.gitlabInfo.DeletePartialMatch(prometheus.Labels{labelBackend: backend})
+	m.gitlabInfo.With(prometheus.Labels{
+		labelBackend:  backend,
+		labelVersion:  version,
+		labelRevision: revision,
+		labelEdition:  edition,
+	}).Set(1)
 }
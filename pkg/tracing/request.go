@@ -4,6 +4,10 @@ import (
 	"context"
 )
 
+// HeaderRequestId is the HTTP header used to
+// propagate the request identifier.
+const HeaderRequestId = "X-Request-ID"
+
 type requestContextKey int
 
 const requestKey requestContextKey = 0
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests which are rejected
+// without contacting the remote end, as it is deemed unavailable.
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+// CircuitState represents the state of a [CircuitBreaker]
+type CircuitState int
+
+const (
+	// CircuitClosed is the regular operating state;
+	// all requests are forwarded.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen is the state after the open period has passed;
+	// a limited number of requests are forwarded to probe the remote end.
+	CircuitHalfOpen
+	// CircuitOpen is the state after too many consecutive failures;
+	// all requests are rejected with [ErrCircuitOpen].
+	CircuitOpen
+)
+
+// CircuitStates contains all possible [CircuitState] values
+var CircuitStates = []CircuitState{CircuitClosed, CircuitHalfOpen, CircuitOpen}
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	}
+
+	return "unknown"
+}
+
+type CircuitBreakerOpts struct {
+	// Number of consecutive failures after which the circuit opens.
+	// Values less than one disable the circuit breaker.
+	FailureThreshold int
+	// Duration the circuit stays open before probing the remote end.
+	OpenTimeout time.Duration
+	// Number of probe requests allowed while the circuit is half-open.
+	HalfOpenRequests int
+	// Callback for state transitions. It is invoked synchronously
+	// and must not call back into the circuit breaker.
+	OnStateChange func(CircuitState)
+	// Time source; defaults to [time.Now]
+	Now func() time.Time
+}
+
+// CircuitBreaker tracks the failures of outgoing requests and
+// rejects requests early once the remote end is deemed unavailable.
+// Network errors and server errors (5xx) count as failures.
+type CircuitBreaker struct {
+	opts CircuitBreakerOpts
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	probes   int
+	openedAt time.Time
+}
+
+func NewCircuitBreaker(opts CircuitBreakerOpts) *CircuitBreaker {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	if opts.HalfOpenRequests < 1 {
+		opts.HalfOpenRequests = 1
+	}
+
+	result := &CircuitBreaker{
+		opts:  opts,
+		state: CircuitClosed,
+	}
+
+	return result
+}
+
+// State returns the current circuit state
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Middleware returns a [Middleware] guarding the decorated
+// [http.RoundTripper] with this circuit breaker.
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if b.opts.FailureThreshold < 1 {
+			return next
+		}
+
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !b.allow() {
+				return nil, ErrCircuitOpen
+			}
+
+			resp, err := next.RoundTrip(r)
+			if err != nil && errors.Is(err, context.Canceled) {
+				// the client gave up, which says nothing about the remote end
+				b.release()
+			} else {
+				b.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.opts.Now().Sub(b.openedAt) < b.opts.OpenTimeout {
+			return false
+		}
+
+		b.transition(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probes >= b.opts.HalfOpenRequests {
+			return false
+		}
+
+		b.probes++
+	}
+
+	return true
+}
+
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case success && b.state == CircuitHalfOpen:
+		b.transition(CircuitClosed)
+	case success:
+		b.failures = 0
+	case b.state == CircuitHalfOpen:
+		b.transition(CircuitOpen)
+	case b.state == CircuitClosed:
+		b.failures++
+		if b.failures >= b.opts.FailureThreshold {
+			b.transition(CircuitOpen)
+		}
+	}
+}
+
+// transition must be called with the lock held
+func (b *CircuitBreaker) transition(state CircuitState) {
+	b.state = state
+	b.failures = 0
+	b.probes = 0
+
+	if state == CircuitOpen {
+		b.openedAt = b.opts.Now()
+	}
+
+	if b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(state)
+	}
+}
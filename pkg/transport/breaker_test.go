@@ -0,0 +1,68 @@
+package transport_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, time.December, 24, 12, 0, 0, 0, time.UTC)
+	status := http.StatusBadGateway
+	calls := 0
+	upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Request: r}, nil
+	})
+	subject := transport.NewCircuitBreaker(transport.CircuitBreakerOpts{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		Now:              func() time.Time { return now },
+	})
+	rt := subject.Middleware()(upstream)
+	roundTrip := func() error {
+		_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := roundTrip(); err != nil {
+			t.Fatalf("RoundTrip() #%d = %v; want no error", i, err)
+		}
+	}
+
+	if got, want := subject.State(), transport.CircuitOpen; got != want {
+		t.Fatalf("State() after failures = %s; want %s", got, want)
+	}
+
+	if err := roundTrip(); !errors.Is(err, transport.ErrCircuitOpen) {
+		t.Errorf("RoundTrip() while open = %v; want %v", err, transport.ErrCircuitOpen)
+	}
+
+	if calls != 2 {
+		t.Errorf("upstream received %d calls; want 2", calls)
+	}
+
+	now = now.Add(time.Minute)
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() after timeout = %v; want no error", err)
+	}
+
+	if got, want := subject.State(), transport.CircuitOpen; got != want {
+		t.Fatalf("State() after failed probe = %s; want %s", got, want)
+	}
+
+	now = now.Add(time.Minute)
+	status = http.StatusOK
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() after recovery = %v; want no error", err)
+	}
+
+	if got, want := subject.State(), transport.CircuitClosed; got != want {
+		t.Errorf("State() after successful probe = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueTimeout is returned for requests which were unable
+// to acquire a slot within the configured time.
+var ErrQueueTimeout = errors.New("Timeout while waiting for a request slot")
+
+// ConcurrencyStatus is a snapshot of the request slot usage
+type ConcurrencyStatus struct {
+	// Number of requests currently in progress
+	InFlight int64
+	// Number of requests waiting for a slot
+	Queued int64
+}
+
+type ConcurrencyLimiterOpts struct {
+	// Maximum number of requests in progress at the same time.
+	// Values less than one disable the limit.
+	MaxRequests int
+	// Maximum time a request waits for a slot before
+	// it is rejected with [ErrQueueTimeout].
+	QueueTimeout time.Duration
+	// Callback invoked with the time a request waited for its slot
+	OnWait func(time.Duration)
+}
+
+// ConcurrencyLimiter bounds the number of requests
+// in progress at the same time. Excess requests are queued
+// until a slot becomes available or the queue timeout passes.
+type ConcurrencyLimiter struct {
+	opts  ConcurrencyLimiterOpts
+	slots chan struct{}
+
+	queued atomic.Int64
+}
+
+func NewConcurrencyLimiter(opts ConcurrencyLimiterOpts) *ConcurrencyLimiter {
+	result := &ConcurrencyLimiter{
+		opts: opts,
+	}
+
+	if opts.MaxRequests > 0 {
+		result.slots = make(chan struct{}, opts.MaxRequests)
+	}
+
+	return result
+}
+
+// Status returns the current slot usage
+func (l *ConcurrencyLimiter) Status() ConcurrencyStatus {
+	result := ConcurrencyStatus{
+		InFlight: int64(len(l.slots)),
+		Queued:   l.queued.Load(),
+	}
+
+	return result
+}
+
+// Middleware returns a [Middleware] which limits the number of
+// concurrent requests passed to the decorated [http.RoundTripper].
+func (l *ConcurrencyLimiter) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if l.slots == nil {
+			return next
+		}
+
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err := l.acquire(r.Context()); err != nil {
+				return nil, err
+			}
+			defer l.release()
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) error {
+	start := time.Now()
+	l.queued.Add(1)
+	defer func() {
+		l.queued.Add(-1)
+		if l.opts.OnWait != nil {
+			l.opts.OnWait(time.Since(start))
+		}
+	}()
+
+	var timeout <-chan time.Time
+	if l.opts.QueueTimeout > 0 {
+		timer := time.NewTimer(l.opts.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	<-l.slots
+}
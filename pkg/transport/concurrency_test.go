@@ -0,0 +1,53 @@
+package transport_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	entered := make(chan struct{})
+	blocker := make(chan struct{})
+	upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		entered <- struct{}{}
+		<-blocker
+		return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+	})
+	subject := transport.NewConcurrencyLimiter(transport.ConcurrencyLimiterOpts{
+		MaxRequests:  1,
+		QueueTimeout: 10 * time.Millisecond,
+	})
+	rt := subject.Middleware()(upstream)
+	roundTrip := func() error {
+		_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		return err
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- roundTrip()
+	}()
+	<-entered
+
+	if got := subject.Status().InFlight; got != 1 {
+		t.Errorf("Status().InFlight = %d; want 1", got)
+	}
+
+	if err := roundTrip(); !errors.Is(err, transport.ErrQueueTimeout) {
+		t.Errorf("RoundTrip() without free slot = %v; want %v", err, transport.ErrQueueTimeout)
+	}
+
+	close(blocker)
+	if err := <-done; err != nil {
+		t.Errorf("RoundTrip() with free slot = %v; want no error", err)
+	}
+
+	if got := subject.Status(); got.InFlight != 0 || got.Queued != 0 {
+		t.Errorf("Status() after completion = %+v; want no usage", got)
+	}
+}
@@ -0,0 +1,21 @@
+package transport
+
+import (
+	"net/http"
+)
+
+// StaticHeaders returns a middleware which sets the given headers
+// on each request, replacing any values already present.
+func StaticHeaders(header http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			// a round tripper must not modify the original request
+			r = r.Clone(r.Context())
+			for k, v := range header {
+				r.Header[k] = v
+			}
+
+			return next.RoundTrip(r)
+		})
+	}
+}
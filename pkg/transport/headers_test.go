@@ -0,0 +1,41 @@
+package transport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestStaticHeaders(t *testing.T) {
+	var got http.Header
+	upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.Header
+		return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+	})
+	header := http.Header{}
+	header.Set("User-Agent", "test/1.0")
+	header.Set("X-Test", "true")
+	rt := transport.StaticHeaders(header)(upstream)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("User-Agent", "client/0.1")
+	req.Header.Set("Private-Token", "secret")
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() = %v; want nil", err)
+	}
+
+	if ua := got.Get("User-Agent"); ua != "test/1.0" {
+		t.Errorf("User-Agent = %q; want %q", ua, "test/1.0")
+	}
+	if v := got.Get("X-Test"); v != "true" {
+		t.Errorf("X-Test = %q; want %q", v, "true")
+	}
+	if v := got.Get("Private-Token"); v != "secret" {
+		t.Errorf("Private-Token = %q; want %q", v, "secret")
+	}
+	if ua := req.Header.Get("User-Agent"); ua != "client/0.1" {
+		t.Errorf("original User-Agent = %q; want unchanged", ua)
+	}
+}
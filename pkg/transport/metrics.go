@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type circuitCollector struct {
+	source func() CircuitState
+
+	state *prometheus.Desc
+}
+
+// NewCircuitBreakerCollector returns a [prometheus.Collector] reporting
+// the circuit state. Each state is represented by its own time series,
+// with the active state having a value of 1.
+func NewCircuitBreakerCollector(source func() CircuitState, namespace string) prometheus.Collector {
+	subsystem := "gitlab"
+
+	state := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "circuit_breaker_state"),
+		"State of the circuit breaker guarding requests against Gitlab.",
+		[]string{"state"}, nil)
+
+	result := &circuitCollector{
+		source: source,
+		state:  state,
+	}
+
+	return result
+}
+
+func (c *circuitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+}
+
+func (c *circuitCollector) Collect(ch chan<- prometheus.Metric) {
+	current := c.source()
+
+	for _, s := range CircuitStates {
+		var v float64
+		if s == current {
+			v = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, v, s.String())
+	}
+}
+
+type rateLimitCollector struct {
+	source func() RateLimitStatus
+
+	limit     *prometheus.Desc
+	remaining *prometheus.Desc
+	reset     *prometheus.Desc
+	throttled *prometheus.Desc
+	rejected  *prometheus.Desc
+}
+
+// NewRateLimitCollector returns a [prometheus.Collector] reporting
+// the rate limit quota as observed on Gitlab responses.
+func NewRateLimitCollector(source func() RateLimitStatus, namespace string) prometheus.Collector {
+	subsystem := "gitlab"
+
+	limit := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "ratelimit_limit"),
+		"Number of requests allowed within the Gitlab rate limit window.",
+		nil, nil)
+	remaining := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "ratelimit_remaining"),
+		"Number of requests left within the current Gitlab rate limit window.",
+		nil, nil)
+	reset := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "ratelimit_reset_timestamp_seconds"),
+		"Time at which the Gitlab rate limit quota is replenished.",
+		nil, nil)
+	throttled := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "ratelimit_throttled_total"),
+		"Number of requests held back to preserve the Gitlab rate limit quota.",
+		nil, nil)
+	rejected := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "ratelimit_rejected_total"),
+		"Number of requests rejected due to an exhausted Gitlab rate limit quota.",
+		nil, nil)
+
+	result := &rateLimitCollector{
+		source:    source,
+		limit:     limit,
+		remaining: remaining,
+		reset:     reset,
+		throttled: throttled,
+		rejected:  rejected,
+	}
+
+	return result
+}
+
+func (c *rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.limit
+	ch <- c.remaining
+	ch <- c.reset
+	ch <- c.throttled
+	ch <- c.rejected
+}
+
+func (c *rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.source()
+	var reset float64
+	if !s.Reset.IsZero() {
+		reset = float64(s.Reset.Unix())
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, float64(s.Limit))
+	ch <- prometheus.MustNewConstMetric(c.remaining, prometheus.GaugeValue, float64(s.Remaining))
+	ch <- prometheus.MustNewConstMetric(c.reset, prometheus.GaugeValue, reset)
+	ch <- prometheus.MustNewConstMetric(c.throttled, prometheus.CounterValue, float64(s.Throttled))
+	ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(s.Rejected))
+}
+
+type concurrencyCollector struct {
+	source func() ConcurrencyStatus
+
+	inFlight *prometheus.Desc
+	queued   *prometheus.Desc
+}
+
+// NewConcurrencyCollector returns a [prometheus.Collector] reporting
+// the usage of the request slots for Gitlab.
+func NewConcurrencyCollector(source func() ConcurrencyStatus, namespace string) prometheus.Collector {
+	subsystem := "gitlab"
+
+	inFlight := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "requests_in_flight"),
+		"Number of HTTP requests against Gitlab currently in progress.",
+		nil, nil)
+	queued := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "requests_queued"),
+		"Number of HTTP requests against Gitlab waiting for a free slot.",
+		nil, nil)
+
+	result := &concurrencyCollector{
+		source:   source,
+		inFlight: inFlight,
+		queued:   queued,
+	}
+
+	return result
+}
+
+func (c *concurrencyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.inFlight
+	ch <- c.queued
+}
+
+func (c *concurrencyCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.source()
+
+	ch <- prometheus.MustNewConstMetric(c.inFlight, prometheus.GaugeValue, float64(s.InFlight))
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(s.Queued))
+}
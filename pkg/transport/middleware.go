@@ -0,0 +1,27 @@
+package transport
+
+import (
+	"net/http"
+)
+
+// Middleware decorates a [http.RoundTripper] with additional behaviour.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of
+// ordinary functions as [http.RoundTripper].
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r).
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Chain applies the given middlewares to the provided [http.RoundTripper].
+// The first middleware is the outermost one, i.e. it sees requests first.
+func Chain(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+
+	return rt
+}
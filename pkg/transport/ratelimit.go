@@ -0,0 +1,247 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// credentialHeaders are the request headers carrying
+// the credential a rate limit quota is tracked for.
+var credentialHeaders = []string{
+	"Authorization",
+	"Private-Token",
+	"Job-Token",
+}
+
+// pruneThreshold is the number of tracked quotas
+// after which replenished ones are discarded.
+const pruneThreshold = 1024
+
+// ErrRateLimited is returned for requests which would have to wait
+// longer than allowed for the rate limit quota to recover.
+var ErrRateLimited = errors.New("Rate limit quota exhausted")
+
+// RateLimitStatus is the rate limit quota as reported by the remote end.
+type RateLimitStatus struct {
+	// Number of requests allowed within the quota window
+	Limit int64
+	// Number of requests left within the current quota window
+	Remaining int64
+	// Time at which the quota is replenished
+	Reset time.Time
+	// Number of requests which waited for the quota to recover
+	Throttled uint64
+	// Number of requests rejected with [ErrRateLimited]
+	Rejected uint64
+}
+
+// rateLimitQuota is the quota of a single credential.
+type rateLimitQuota struct {
+	limit        int64
+	remaining    int64
+	reset        time.Time
+	blockedUntil time.Time
+}
+
+// replenished reports whether the quota imposes
+// no restrictions beyond the given point in time.
+func (q *rateLimitQuota) replenished(now time.Time) bool {
+	return !now.Before(q.reset) && !now.Before(q.blockedUntil)
+}
+
+type RateLimiterOpts struct {
+	// Number of requests to keep in reserve. Once the remaining
+	// quota drops to this value, requests are held back until
+	// the quota is replenished.
+	Reserve int64
+	// Maximum time a request is held back before it is
+	// rejected with [ErrRateLimited].
+	MaxWait time.Duration
+	// Time source; defaults to [time.Now]
+	Now func() time.Time
+}
+
+// RateLimiter tracks the rate limit quota reported by the remote end
+// via RateLimit-* headers and delays outgoing requests before the
+// quota runs out. Responses with status 429 suspend requests
+// for the period announced via the Retry-After header.
+// Gitlab enforces its limits per user, which is why the quota is
+// tracked separately for each credential (identified by a hash
+// of the respective request header).
+type RateLimiter struct {
+	opts RateLimiterOpts
+
+	mu     sync.Mutex
+	status RateLimitStatus
+	quotas map[[sha256.Size]byte]*rateLimitQuota
+}
+
+func NewRateLimiter(opts RateLimiterOpts) *RateLimiter {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	result := &RateLimiter{
+		opts:   opts,
+		quotas: make(map[[sha256.Size]byte]*rateLimitQuota),
+	}
+
+	return result
+}
+
+// Status returns the most recently observed rate limit quota
+// (regardless of the credential it belongs to)
+func (l *RateLimiter) Status() RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.status
+}
+
+// Middleware returns a [Middleware] which throttles requests
+// passed to the decorated [http.RoundTripper].
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			key := credentialKey(r)
+			if err := l.wait(r, key); err != nil {
+				return nil, err
+			}
+
+			resp, err := next.RoundTrip(r)
+			if err == nil {
+				l.observe(resp, key)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+func (l *RateLimiter) wait(r *http.Request, key [sha256.Size]byte) error {
+	d := l.delay(key)
+	if d <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	rejected := d > l.opts.MaxWait
+	if rejected {
+		l.status.Rejected++
+	} else {
+		l.status.Throttled++
+	}
+	l.mu.Unlock()
+
+	if rejected {
+		return ErrRateLimited
+	}
+
+	return sleep(r.Context(), d)
+}
+
+func (l *RateLimiter) delay(key [sha256.Size]byte) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q, ok := l.quotas[key]
+	if !ok {
+		return 0
+	}
+
+	until := q.blockedUntil
+	if q.limit > 0 && q.remaining <= l.opts.Reserve && q.reset.After(until) {
+		until = q.reset
+	}
+
+	return until.Sub(l.opts.Now())
+}
+
+func (l *RateLimiter) observe(resp *http.Response, key [sha256.Size]byte) {
+	limit, limitErr := strconv.ParseInt(resp.Header.Get(HeaderRateLimitLimit), 10, 64)
+	remaining, remainingErr := strconv.ParseInt(resp.Header.Get(HeaderRateLimitRemaining), 10, 64)
+	reset, resetErr := strconv.ParseInt(resp.Header.Get(HeaderRateLimitReset), 10, 64)
+	quota := limitErr == nil && remainingErr == nil && resetErr == nil
+	throttled := resp.StatusCode == http.StatusTooManyRequests
+	if !quota && !throttled {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.Now()
+	q := l.quota(key, now)
+	if quota {
+		q.limit = limit
+		q.remaining = remaining
+		q.reset = time.Unix(reset, 0)
+		l.status.Limit = limit
+		l.status.Remaining = remaining
+		l.status.Reset = q.reset
+	}
+
+	if !throttled {
+		return
+	}
+
+	retry := resp.Header.Get(HeaderRetryAfter)
+	if seconds, err := strconv.ParseInt(retry, 10, 64); err == nil {
+		q.blockedUntil = now.Add(time.Duration(seconds) * time.Second)
+	} else if date, err := http.ParseTime(retry); err == nil {
+		q.blockedUntil = date
+	} else if resetErr == nil {
+		q.blockedUntil = time.Unix(reset, 0)
+	}
+}
+
+// quota returns the tracked quota of the given credential,
+// discarding replenished ones if too many are tracked already.
+// The caller must hold the lock.
+func (l *RateLimiter) quota(key [sha256.Size]byte, now time.Time) *rateLimitQuota {
+	if q, ok := l.quotas[key]; ok {
+		return q
+	}
+
+	if len(l.quotas) >= pruneThreshold {
+		for k, q := range l.quotas {
+			if q.replenished(now) {
+				delete(l.quotas, k)
+			}
+		}
+	}
+
+	q := new(rateLimitQuota)
+	l.quotas[key] = q
+
+	return q
+}
+
+// credentialKey returns the hash of the credential used
+// by the given request. Unauthenticated requests share
+// the hash of an empty credential.
+func credentialKey(r *http.Request) [sha256.Size]byte {
+	h := sha256.New()
+	for _, name := range credentialHeaders {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(r.Header.Get(name)))
+		h.Write([]byte{0})
+	}
+
+	var result [sha256.Size]byte
+	h.Sum(result[:0])
+
+	return result
+}
@@ -0,0 +1,111 @@
+package transport_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, time.December, 24, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(time.Minute)
+	status := http.StatusOK
+	remaining := 50
+	upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: status, Request: r, Header: http.Header{}}
+		resp.Header.Set(transport.HeaderRateLimitLimit, "100")
+		resp.Header.Set(transport.HeaderRateLimitRemaining, strconv.Itoa(remaining))
+		resp.Header.Set(transport.HeaderRateLimitReset, strconv.FormatInt(reset.Unix(), 10))
+		resp.Header.Set(transport.HeaderRetryAfter, "30")
+		return resp, nil
+	})
+	subject := transport.NewRateLimiter(transport.RateLimiterOpts{
+		Reserve: 10,
+		MaxWait: time.Second,
+		Now:     func() time.Time { return now },
+	})
+	rt := subject.Middleware()(upstream)
+	roundTrip := func() error {
+		_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		return err
+	}
+
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() with quota = %v; want no error", err)
+	}
+
+	got := subject.Status()
+	if got.Limit != 100 || got.Remaining != 50 || !got.Reset.Equal(reset) {
+		t.Errorf("Status() = %+v; want limit 100, remaining 50, reset %s", got, reset)
+	}
+
+	status = http.StatusTooManyRequests
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() on 429 = %v; want no error", err)
+	}
+
+	if err := roundTrip(); !errors.Is(err, transport.ErrRateLimited) {
+		t.Errorf("RoundTrip() after 429 = %v; want %v", err, transport.ErrRateLimited)
+	}
+
+	now = now.Add(30 * time.Second)
+	status = http.StatusOK
+	remaining = 5
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() after Retry-After = %v; want no error", err)
+	}
+
+	if err := roundTrip(); !errors.Is(err, transport.ErrRateLimited) {
+		t.Errorf("RoundTrip() below reserve = %v; want %v", err, transport.ErrRateLimited)
+	}
+
+	// close enough to the reset to wait for it
+	now = reset.Add(-10 * time.Millisecond)
+	if err := roundTrip(); err != nil {
+		t.Fatalf("RoundTrip() shortly before reset = %v; want no error", err)
+	}
+
+	if got := subject.Status(); got.Throttled != 1 || got.Rejected != 2 {
+		t.Errorf("Status() = %+v; want 1 throttled, 2 rejected", got)
+	}
+}
+
+func TestRateLimiterCredentials(t *testing.T) {
+	now := time.Date(2024, time.December, 24, 12, 0, 0, 0, time.UTC)
+	upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Request: r, Header: http.Header{}}
+		if r.Header.Get("Private-Token") == "glpat-heavy" {
+			resp.StatusCode = http.StatusTooManyRequests
+			resp.Header.Set(transport.HeaderRetryAfter, "30")
+		}
+		return resp, nil
+	})
+	subject := transport.NewRateLimiter(transport.RateLimiterOpts{
+		MaxWait: time.Second,
+		Now:     func() time.Time { return now },
+	})
+	rt := subject.Middleware()(upstream)
+	roundTrip := func(token string) error {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.Header.Set("Private-Token", token)
+		_, err := rt.RoundTrip(r)
+		return err
+	}
+
+	if err := roundTrip("glpat-heavy"); err != nil {
+		t.Fatalf("RoundTrip() on 429 = %v; want no error", err)
+	}
+
+	if err := roundTrip("glpat-heavy"); !errors.Is(err, transport.ErrRateLimited) {
+		t.Errorf("RoundTrip() of throttled credential = %v; want %v", err, transport.ErrRateLimited)
+	}
+
+	if err := roundTrip("glpat-light"); err != nil {
+		t.Errorf("RoundTrip() of other credential = %v; want no error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+type RetryOpts struct {
+	// Maximum number of retries after the initial attempt.
+	// Values less than one disable retries.
+	MaxRetries int
+	// Delay before the first retry, doubled for each subsequent one
+	BaseDelay time.Duration
+	// Upper bound of the random delay added to each backoff
+	Jitter time.Duration
+	// Time budget for all attempts combined; no retry is
+	// attempted if it would exceed this budget.
+	Deadline time.Duration
+	// Callback invoked before each retry
+	OnRetry func(r *http.Request, attempt int)
+}
+
+// Retrier repeats idempotent requests (GET, HEAD) which failed
+// due to network errors or transient server errors (502, 503, 504).
+// Throttled requests (429) are left to the [RateLimiter].
+// All attempts are bound to the request context.
+type Retrier struct {
+	opts RetryOpts
+}
+
+func NewRetrier(opts RetryOpts) *Retrier {
+	result := &Retrier{
+		opts: opts,
+	}
+
+	return result
+}
+
+// Middleware returns a [Middleware] which retries requests
+// passed to the decorated [http.RoundTripper].
+func (t *Retrier) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if t.opts.MaxRetries < 1 {
+			return next
+		}
+
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				return next.RoundTrip(r)
+			}
+
+			start := time.Now()
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(r)
+				if attempt > t.opts.MaxRetries || !retryable(resp, err) {
+					return resp, err
+				}
+
+				delay := t.backoff(attempt)
+				if t.opts.Deadline > 0 && time.Since(start)+delay > t.opts.Deadline {
+					return resp, err
+				}
+
+				if resp != nil {
+					// release the connection for reuse
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				if err := sleep(r.Context(), delay); err != nil {
+					return nil, err
+				}
+
+				if t.opts.OnRetry != nil {
+					t.opts.OnRetry(r, attempt)
+				}
+			}
+		})
+	}
+}
+
+func (t *Retrier) backoff(attempt int) time.Duration {
+	delay := t.opts.BaseDelay << (attempt - 1)
+	if t.opts.Jitter > 0 {
+		delay += rand.N(t.opts.Jitter)
+	}
+
+	return delay
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// neither the caller giving up nor local
+		// protection mechanisms warrant another attempt
+		return !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrCircuitOpen) &&
+			!errors.Is(err, ErrRateLimited) &&
+			!errors.Is(err, ErrQueueTimeout)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
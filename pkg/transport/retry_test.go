@@ -0,0 +1,101 @@
+package transport_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UiP9AV6Y/kubernetes-gitlab-authn/pkg/transport"
+)
+
+func TestRetrier(t *testing.T) {
+	tests := map[string]struct {
+		haveMethod   string
+		haveFailures []error
+		haveStatus   int
+		wantCalls    int
+		wantRetries  int
+	}{
+		"success": {
+			haveMethod: http.MethodGet,
+			haveStatus: http.StatusOK,
+			wantCalls:  1,
+		},
+		"network_error": {
+			haveMethod:   http.MethodGet,
+			haveFailures: []error{errors.New("connection reset by peer")},
+			haveStatus:   http.StatusOK,
+			wantCalls:    2,
+			wantRetries:  1,
+		},
+		"bad_gateway": {
+			haveMethod:  http.MethodGet,
+			haveStatus:  http.StatusBadGateway,
+			wantCalls:   3,
+			wantRetries: 2,
+		},
+		"too_many_requests": {
+			haveMethod: http.MethodGet,
+			haveStatus: http.StatusTooManyRequests,
+			wantCalls:  1,
+		},
+		"not_found": {
+			haveMethod: http.MethodGet,
+			haveStatus: http.StatusNotFound,
+			wantCalls:  1,
+		},
+		"circuit_open": {
+			haveMethod:   http.MethodGet,
+			haveFailures: []error{transport.ErrCircuitOpen},
+			haveStatus:   http.StatusOK,
+			wantCalls:    1,
+		},
+		"not_idempotent": {
+			haveMethod:  http.MethodPost,
+			haveStatus:  http.StatusBadGateway,
+			wantCalls:   1,
+			wantRetries: 0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls, retries := 0, 0
+			upstream := transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				if calls <= len(test.haveFailures) {
+					return nil, test.haveFailures[calls-1]
+				}
+
+				return &http.Response{
+					StatusCode: test.haveStatus,
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    r,
+				}, nil
+			})
+			subject := transport.NewRetrier(transport.RetryOpts{
+				MaxRetries: 2,
+				BaseDelay:  time.Millisecond,
+				Deadline:   time.Second,
+				OnRetry: func(_ *http.Request, _ int) {
+					retries++
+				},
+			})
+
+			req := httptest.NewRequest(test.haveMethod, "http://example.com/", nil)
+			_, _ = subject.Middleware()(upstream).RoundTrip(req)
+
+			if calls != test.wantCalls {
+				t.Errorf("upstream received %d calls; want %d", calls, test.wantCalls)
+			}
+
+			if retries != test.wantRetries {
+				t.Errorf("OnRetry called %d times; want %d", retries, test.wantRetries)
+			}
+		})
+	}
+}